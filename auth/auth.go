@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/trevor-atlas/zilla/secrets"
+	"github.com/trevor-atlas/zilla/util"
+	"net/http"
+)
+
+// ErrNotLoggedIn is returned by oauth2 credentials until zilla auth login has been run
+var ErrNotLoggedIn = errors.New("not logged in to jira")
+
+// Credentials sign requests to jira
+type Credentials interface {
+	util.Authenticator
+	// Type is the auth type, one of util.AuthBasic, util.AuthPAT or util.AuthOAuth2
+	Type() string
+	// BaseURL returns where the REST api of a jira site is reached with these credentials
+	BaseURL(site string) string
+}
+
+// New returns the credentials described by the jira section of the config, or
+// by profile, looking up whichever of its credentials name a secret in store
+func New(conf util.Jiraconf, store secrets.Store, profile string) (Credentials, error) {
+	switch conf.AuthType() {
+	case util.AuthBasic:
+		token, err := secrets.Resolve(store, conf.Apikey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the apikey: %w", err)
+		}
+		return Basic{Username: conf.Username, Token: token}, nil
+	case util.AuthPAT:
+		token, err := secrets.Resolve(store, conf.AccessToken)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the accessToken: %w", err)
+		}
+		return PAT{Token: token}, nil
+	case util.AuthOAuth2:
+		secret, err := secrets.Resolve(store, conf.Auth.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the clientSecret: %w", err)
+		}
+		conf.Auth.ClientSecret = secret
+		return NewOAuth2(conf.Auth, store, profile)
+	}
+	return nil, fmt.Errorf("unknown auth type %q, expected %s, %s or %s", conf.Auth.Type, util.AuthBasic, util.AuthPAT, util.AuthOAuth2)
+}
+
+// Load returns the credentials described by app's config, with its secrets from app's secret store
+func Load(app *util.Zilla) (Credentials, error) {
+	store, err := app.Secrets()
+	if err != nil {
+		return nil, err
+	}
+	return New(app.Config.Jira, store, app.Config.Profile)
+}
+
+// Broken stands in for credentials that couldn't be set up, every request fails with err
+func Broken(err error) Credentials {
+	return broken{err}
+}
+
+// Basic signs in with a username and API token (or password, on old Jira Server installs)
+type Basic struct {
+	Username string
+	Token    string
+}
+
+func (b Basic) Type() string               { return util.AuthBasic }
+func (b Basic) BaseURL(site string) string { return site }
+
+func (b Basic) Authenticate(ctx context.Context, req *http.Request) error {
+	if b.Username == "" || b.Token == "" {
+		return errors.New("basic auth needs a username and apikey in the jira section of the config, zilla setup asks for them")
+	}
+	key := base64.StdEncoding.EncodeToString([]byte(b.Username + ":" + b.Token))
+	req.Header.Set("Authorization", "Basic "+key)
+	return nil
+}
+
+// PAT signs in with a Jira Server or Data Center personal access token
+type PAT struct {
+	Token string
+}
+
+func (p PAT) Type() string               { return util.AuthPAT }
+func (p PAT) BaseURL(site string) string { return site }
+
+func (p PAT) Authenticate(ctx context.Context, req *http.Request) error {
+	if p.Token == "" {
+		return errors.New("pat auth needs an accessToken in the jira section of the config, zilla setup asks for it")
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	return nil
+}
+
+type broken struct {
+	err error
+}
+
+func (b broken) Type() string                                              { return "" }
+func (b broken) BaseURL(site string) string                                { return site }
+func (b broken) Authenticate(ctx context.Context, req *http.Request) error { return b.err }
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/trevor-atlas/zilla/constants"
+	"github.com/trevor-atlas/zilla/dirs"
+	"github.com/trevor-atlas/zilla/secrets"
+	"github.com/trevor-atlas/zilla/util"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authorizeURL = "https://auth.atlassian.com/authorize"
+	tokenURL     = "https://auth.atlassian.com/oauth/token"
+	resourcesURL = "https://api.atlassian.com/oauth/token/accessible-resources"
+	apiURL       = "https://api.atlassian.com/ex/jira/"
+	// offline_access is what gets us a refresh token
+	scopes = "read:jira-work write:jira-work read:jira-user offline_access"
+
+	// DefaultCallbackPort is used when the config doesn't set auth.callbackPort
+	DefaultCallbackPort = 8765
+	// loginTimeout is how long login waits for the browser to come back
+	loginTimeout = 5 * time.Minute
+)
+
+// Token is an OAuth 2.0 access token along with the jira site it was granted for
+type Token struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	Expiry       time.Time `json:"expiry"`
+	CloudID      string    `json:"cloudId"`
+	SiteURL      string    `json:"siteUrl"`
+}
+
+// legacyTokenPath is where the token was kept before it moved to the secret store, ~/.config/zilla/token.json
+func legacyTokenPath() (string, error) {
+	dir, err := dirs.LegacyDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, constants.TOKEN_FILENAME), nil
+}
+
+// loadToken reads the token from store, moving a token.json left by an older
+// zilla into it first. It returns nil when not logged in.
+func loadToken(store secrets.Store, tokenSecret string) (*Token, error) {
+	// token.json is from before profiles, so it belongs with the jira section of the config
+	if tokenSecret == util.SecretName("", "oauth-token") {
+		if err := migrateToken(store, tokenSecret); err != nil {
+			return nil, err
+		}
+	}
+	contents, err := store.Get(tokenSecret)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the oauth token: %w", err)
+	}
+	var token Token
+	if err := json.Unmarshal([]byte(contents), &token); err != nil {
+		return nil, fmt.Errorf("error parsing the oauth token: %s", err)
+	}
+	return &token, nil
+}
+
+func migrateToken(store secrets.Store, tokenSecret string) error {
+	tokenPath, err := legacyTokenPath()
+	if err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadFile(tokenPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var token Token
+	if err := json.Unmarshal(contents, &token); err != nil {
+		return fmt.Errorf("error parsing %s: %s", tokenPath, err)
+	}
+	if err := token.save(store, tokenSecret); err != nil {
+		return fmt.Errorf("error moving %s to the secret store: %w", tokenPath, err)
+	}
+	return os.Remove(tokenPath)
+}
+
+func (t Token) save(store secrets.Store, tokenSecret string) error {
+	contents, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return store.Set(tokenSecret, string(contents))
+}
+
+// tokenResponse is the token endpoint's answer to both a code exchange and a refresh
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// OAuth2 signs in with an Atlassian OAuth 2.0 (3LO) app. The token from
+// zilla auth login is kept in the secret store and refreshed when it expires.
+type OAuth2 struct {
+	conf  util.AuthConf
+	store secrets.Store
+	// tokenSecret is the name the token is kept under in store, each profile has its own
+	tokenSecret string
+	// mu guards token, requests from several goroutines can find it expired at once
+	mu    sync.Mutex
+	token *Token
+}
+
+// NewOAuth2 returns oauth2 credentials, loading the token saved by a previous login if there is one
+func NewOAuth2(conf util.AuthConf, store secrets.Store, profile string) (*OAuth2, error) {
+	if conf.ClientID == "" || conf.ClientSecret == "" {
+		return nil, errors.New("oauth2 auth needs a clientId and clientSecret in the jira.auth section of the config")
+	}
+	if conf.CallbackPort == 0 {
+		conf.CallbackPort = DefaultCallbackPort
+	}
+	tokenSecret := util.SecretName(profile, "oauth-token")
+	token, err := loadToken(store, tokenSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuth2{conf: conf, store: store, tokenSecret: tokenSecret, token: token}, nil
+}
+
+func (o *OAuth2) Type() string { return util.AuthOAuth2 }
+
+// BaseURL is the api gateway for the site the token was granted for, OAuth 2.0
+// tokens aren't accepted by the site itself
+func (o *OAuth2) BaseURL(site string) string {
+	token := o.Token()
+	if token == nil {
+		return site
+	}
+	return apiURL + token.CloudID
+}
+
+// Token returns a copy of the current token, nil when not logged in
+func (o *OAuth2) Token() *Token {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token == nil {
+		return nil
+	}
+	token := *o.token
+	return &token
+}
+
+func (o *OAuth2) Authenticate(ctx context.Context, req *http.Request) error {
+	token, err := o.validToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// validToken returns the access token, refreshing it first if it has expired or is about to
+func (o *OAuth2) validToken(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token == nil {
+		return "", ErrNotLoggedIn
+	}
+	if time.Until(o.token.Expiry) > time.Minute {
+		return o.token.AccessToken, nil
+	}
+
+	var refreshed tokenResponse
+	_, err := util.NewHTTP().
+		WithContext(ctx).
+		Url(tokenURL).
+		JSON(map[string]string{
+			"grant_type":    "refresh_token",
+			"client_id":     o.conf.ClientID,
+			"client_secret": o.conf.ClientSecret,
+			"refresh_token": o.token.RefreshToken,
+		}).
+		Decode(&refreshed).
+		POST()
+	if err != nil {
+		var statusErr *util.StatusError
+		if errors.As(err, &statusErr) {
+			// the refresh token has expired or been revoked
+			return "", fmt.Errorf("%w (%s)", ErrNotLoggedIn, statusErr.Body)
+		}
+		return "", fmt.Errorf("error refreshing the oauth token: %w", err)
+	}
+
+	token := *o.token
+	token.AccessToken = refreshed.AccessToken
+	token.Expiry = time.Now().Add(time.Duration(refreshed.ExpiresIn) * time.Second)
+	// refresh tokens rotate, the old one stops working once the new one is issued
+	if refreshed.RefreshToken != "" {
+		token.RefreshToken = refreshed.RefreshToken
+	}
+	if err := token.save(o.store, o.tokenSecret); err != nil {
+		return "", fmt.Errorf("error saving the refreshed oauth token: %s", err)
+	}
+	o.token = &token
+	return token.AccessToken, nil
+}
+
+// Login runs the authorization code flow: open is called with the url to visit
+// in a browser, and a listener on 127.0.0.1 waits for it to redirect back with a
+// code, which is exchanged for a token for site and saved.
+func (o *OAuth2) Login(ctx context.Context, site string, open func(url string)) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", o.conf.CallbackPort))
+	if err != nil {
+		return fmt.Errorf("couldn't listen for the login callback: %s", err)
+	}
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr())
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+
+	type callback struct {
+		code string
+		err  error
+	}
+	callbacks := make(chan callback, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		var result callback
+		switch {
+		case query.Get("state") != state:
+			result.err = errors.New("the login callback didn't come from the login zilla started")
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("login failed: %s", query.Get("error_description"))
+		default:
+			result.code = query.Get("code")
+		}
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "zilla is logged in, you can close this tab")
+		}
+		select {
+		case callbacks <- result:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	query := url.Values{}
+	query.Set("audience", "api.atlassian.com")
+	query.Set("client_id", o.conf.ClientID)
+	query.Set("scope", scopes)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", state)
+	query.Set("response_type", "code")
+	query.Set("prompt", "consent")
+	open(authorizeURL + "?" + query.Encode())
+
+	var result callback
+	select {
+	case result = <-callbacks:
+	case <-time.After(loginTimeout):
+		return errors.New("timed out waiting for the browser to log in")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if result.err != nil {
+		return result.err
+	}
+
+	var granted tokenResponse
+	_, err = util.NewHTTP().
+		WithContext(ctx).
+		Url(tokenURL).
+		JSON(map[string]string{
+			"grant_type":    "authorization_code",
+			"client_id":     o.conf.ClientID,
+			"client_secret": o.conf.ClientSecret,
+			"code":          result.code,
+			"redirect_uri":  redirectURI,
+		}).
+		Decode(&granted).
+		POST()
+	if err != nil {
+		return fmt.Errorf("error exchanging the login code for a token: %w", err)
+	}
+
+	token := Token{
+		AccessToken:  granted.AccessToken,
+		RefreshToken: granted.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(granted.ExpiresIn) * time.Second),
+	}
+	token.CloudID, token.SiteURL, err = findSite(ctx, token.AccessToken, site)
+	if err != nil {
+		return err
+	}
+	if err := token.save(o.store, o.tokenSecret); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.token = &token
+	o.mu.Unlock()
+	return nil
+}
+
+// Logout forgets the saved token
+func (o *OAuth2) Logout() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.token = nil
+	return o.store.Delete(o.tokenSecret)
+}
+
+// findSite picks the cloud id of site from the sites the token can reach
+func findSite(ctx context.Context, accessToken, site string) (string, string, error) {
+	var resources []struct {
+		ID   string `json:"id"`
+		URL  string `json:"url"`
+		Name string `json:"name"`
+	}
+	_, err := util.NewHTTP().
+		WithContext(ctx).
+		Url(resourcesURL).
+		WithHeader("Authorization", "Bearer "+accessToken).
+		WithHeader("Accept", "application/json").
+		Decode(&resources).
+		GET()
+	if err != nil {
+		return "", "", fmt.Errorf("error listing the sites the token can reach: %w", err)
+	}
+	var urls []string
+	for _, r := range resources {
+		if strings.TrimSuffix(r.URL, "/") == strings.TrimSuffix(site, "/") {
+			return r.ID, r.URL, nil
+		}
+		urls = append(urls, r.URL)
+	}
+	return "", "", fmt.Errorf("the login wasn't granted access to %s, only: %s", site, strings.Join(urls, ", "))
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,21 +1,346 @@
 package cache
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/trevor-atlas/zilla/constants"
+	"github.com/trevor-atlas/zilla/dirs"
 	"github.com/trevor-atlas/zilla/jira"
-	"github.com/trevor-atlas/zilla/util"
+	bolt "go.etcd.io/bbolt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
+	"sync"
+	"time"
 )
 
-func GetCachedIssues() *jira.JiraIssues {
-	// get cached issues from fs
-	// I want to store these cached issues in a json file and then quickly read them to make the UI responsive faster
-	home, err := os.UserHomeDir()
+var (
+	issuesBucket  = []byte("issues")  // issue key -> issue
+	queriesBucket = []byte("queries") // jql -> query
+	termsBucket   = []byte("terms")   // issue key -> the terms it is indexed under
+	indexBucket   = []byte("index")   // term + "\x00" + issue key -> nothing
+	metaBucket    = []byte("meta")
+	lastQueryKey  = []byte("lastQuery")
+)
+
+// Entry is the last set of results seen for a JQL query
+type Entry struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	// HighWater is the most recent update time of any issue in the entry,
+	// the next sync only asks for issues updated since then
+	HighWater time.Time `json:"highWater"`
+	// ReconciledAt is when the entry was last checked against the full list of matching issues
+	ReconciledAt time.Time       `json:"reconciledAt"`
+	Issues       jira.JiraIssues `json:"issues"`
+}
+
+// query is how an entry is stored, issues are kept once in their own bucket and referenced by key
+type query struct {
+	FetchedAt    time.Time                   `json:"fetchedAt"`
+	HighWater    time.Time                   `json:"highWater"`
+	ReconciledAt time.Time                   `json:"reconciledAt"`
+	Total        int                         `json:"total"`
+	Keys         []string                    `json:"keys"`
+	Names        map[string]string           `json:"names,omitempty"`
+	Schema       map[string]jira.FieldSchema `json:"schema,omitempty"`
+}
+
+// Cache is a local database of every issue zilla has synced and the results of
+// recent searches, so the UI has something to show straight away while fresh
+// results are fetched and issues can be searched offline.
+//
+// The database is only held open for the length of each read or write so that
+// `zilla grep` can be run while the UI is open.
+type Cache struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Path is where the database for profile is stored, $XDG_CACHE_HOME/zilla/zilla.db
+// for the jira section of the config and zilla-<profile>.db for a profile
+func Path(profile string) (string, error) {
+	dir, err := dirs.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(profile, `/\`) {
+		return "", fmt.Errorf("the profile name %q can't be used in a file name", profile)
+	}
+	filename := constants.DB_FILENAME
+	if profile != "" {
+		ext := path.Ext(filename)
+		filename = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(filename, ext), profile, ext)
+	}
+	return path.Join(dir, filename), nil
+}
+
+// Load opens the local database for profile, each profile's issues are kept
+// apart. A database left in ~/.config/zilla by an older zilla is moved to the
+// cache directory, and the database for the jira section of the config also
+// picks up anything left in the old json cache file. A missing database is an
+// empty one, not an error.
+func Load(profile string) (*Cache, error) {
+	c := &Cache{}
+	dbPath, err := Path(profile)
+	if err != nil {
+		return c, err
+	}
+	c.path = dbPath
+	if err := dirs.Ensure(path.Dir(dbPath)); err != nil {
+		return c, err
+	}
+	legacyDir, err := dirs.LegacyDir()
+	if err != nil {
+		return c, err
+	}
+	// the database holds the outbox, so it's moved rather than started over
+	if err := dirs.Move(path.Join(legacyDir, path.Base(dbPath)), dbPath); err != nil {
+		return c, fmt.Errorf("error moving the issue database to %s: %w", path.Dir(dbPath), err)
+	}
+	if profile != "" {
+		return c, nil
+	}
+	return c, c.migrate(legacyDir)
+}
+
+// migrate imports the json file in dir searches used to be cached in, then removes it
+func (c *Cache) migrate(dir string) error {
+	legacyPath := path.Join(dir, constants.CACHE_FILENAME)
+	contents, err := ioutil.ReadFile(legacyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var legacy struct {
+		LastQuery string           `json:"lastQuery"`
+		Queries   map[string]Entry `json:"queries"`
+	}
+	// a cache we can't read is as good as no cache, so it is dropped either way
+	if err := json.Unmarshal(contents, &legacy); err == nil {
+		for jql, entry := range legacy.Queries {
+			if jql == legacy.LastQuery {
+				continue
+			}
+			if err := c.Put(jql, entry); err != nil {
+				return err
+			}
+		}
+		if entry, ok := legacy.Queries[legacy.LastQuery]; ok {
+			if err := c.Put(legacy.LastQuery, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Remove(legacyPath)
+}
+
+func (c *Cache) open(readOnly bool) (*bolt.DB, error) {
+	if c.path == "" {
+		return nil, errors.New("no cache path")
+	}
+	if !readOnly {
+		if err := os.MkdirAll(path.Dir(c.path), 0700); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(c.path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: readOnly})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("the issue database at %s is locked by another process", c.path)
+	}
+	return db, err
+}
+
+// view runs fn in a read only transaction. Nothing is run if the database hasn't been created yet.
+func (c *Cache) view(fn func(tx *bolt.Tx) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := os.Stat(c.path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := c.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, with every bucket created
+func (c *Cache) update(fn func(tx *bolt.Tx) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db, err := c.open(false)
 	if err != nil {
-		fmt.Println("error reading filesystem")
+		return err
 	}
-	issuesPath := path.Join(home, util.CONFIG_DIR, util.CACHE_FILENAME)
-	os.Open(issuesPath)
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{issuesBucket, queriesBucket, termsBucket, indexBucket, metaBucket, outboxBucket, responsesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// Get returns the cached results for a query
+func (c *Cache) Get(jql string) (Entry, bool) {
+	var entry Entry
+	var found bool
+	err := c.view(func(tx *bolt.Tx) error {
+		entry, found = getEntry(tx, jql)
+		return nil
+	})
+	return entry, found && err == nil
+}
+
+// Last returns the most recently cached query and its results
+func (c *Cache) Last() (string, Entry, bool) {
+	var jql string
+	var entry Entry
+	var found bool
+	err := c.view(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(metaBucket); meta != nil {
+			jql = string(meta.Get(lastQueryKey))
+		}
+		entry, found = getEntry(tx, jql)
+		return nil
+	})
+	return jql, entry, found && err == nil
+}
+
+// Put records the results of a query, storing and indexing each of its issues
+func (c *Cache) Put(jql string, entry Entry) error {
+	return c.update(func(tx *bolt.Tx) error {
+		return putEntry(tx, jql, entry)
+	})
+}
+
+// modify reads the results for a query, changes them with fn and writes them
+// back in a single transaction, so that a sync and a page being cached at the
+// same time can't undo each other
+func (c *Cache) modify(jql string, fn func(entry Entry, found bool) Entry) (Entry, error) {
+	var entry Entry
+	err := c.update(func(tx *bolt.Tx) error {
+		entry = fn(getEntry(tx, jql))
+		return putEntry(tx, jql, entry)
+	})
+	return entry, err
+}
 
+// Issue returns a synced issue by key
+func (c *Cache) Issue(key string) (jira.JiraIssue, bool) {
+	var issue jira.JiraIssue
+	var found bool
+	err := c.view(func(tx *bolt.Tx) error {
+		if tx.Bucket(issuesBucket) != nil {
+			issue, found = getIssue(tx, key)
+		}
+		return nil
+	})
+	return issue, found && err == nil
+}
+
+// PutIssue stores a single issue, such as one loaded in full with its comments
+func (c *Cache) PutIssue(issue jira.JiraIssue) error {
+	return c.update(func(tx *bolt.Tx) error {
+		return putIssue(tx, issue)
+	})
+}
+
+func putEntry(tx *bolt.Tx, jql string, entry Entry) error {
+	q := query{
+		FetchedAt:    entry.FetchedAt,
+		HighWater:    entry.HighWater,
+		ReconciledAt: entry.ReconciledAt,
+		Total:        entry.Issues.Total,
+		Names:        entry.Issues.Names,
+		Schema:       entry.Issues.Schema,
+	}
+	for _, issue := range entry.Issues.Issues {
+		if err := putIssue(tx, issue); err != nil {
+			return err
+		}
+		q.Keys = append(q.Keys, issue.Key)
+	}
+	contents, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(queriesBucket).Put([]byte(jql), contents); err != nil {
+		return err
+	}
+	return tx.Bucket(metaBucket).Put(lastQueryKey, []byte(jql))
+}
+
+func getEntry(tx *bolt.Tx, jql string) (Entry, bool) {
+	queries := tx.Bucket(queriesBucket)
+	if queries == nil {
+		return Entry{}, false
+	}
+	contents := queries.Get([]byte(jql))
+	if contents == nil {
+		return Entry{}, false
+	}
+	var q query
+	if err := json.Unmarshal(contents, &q); err != nil {
+		return Entry{}, false
+	}
+	entry := Entry{
+		FetchedAt:    q.FetchedAt,
+		HighWater:    q.HighWater,
+		ReconciledAt: q.ReconciledAt,
+		Issues: jira.JiraIssues{
+			MaxResults: len(q.Keys),
+			Total:      q.Total,
+			Names:      q.Names,
+			Schema:     q.Schema,
+		},
+	}
+	for _, key := range q.Keys {
+		if issue, ok := getIssue(tx, key); ok {
+			entry.Issues.Issues = append(entry.Issues.Issues, issue)
+		}
+	}
+	return entry, true
+}
+
+func getIssue(tx *bolt.Tx, key string) (jira.JiraIssue, bool) {
+	var issue jira.JiraIssue
+	contents := tx.Bucket(issuesBucket).Get([]byte(key))
+	if contents == nil {
+		return issue, false
+	}
+	if err := json.Unmarshal(contents, &issue); err != nil {
+		return issue, false
+	}
+	return issue, true
+}
+
+func putIssue(tx *bolt.Tx, issue jira.JiraIssue) error {
+	// search results don't always carry comments, hang on to the ones
+	// we have unless the issue has changed since they were loaded
+	if stored, ok := getIssue(tx, issue.Key); ok && len(issue.Fields.Comment.Comments) == 0 && sameUpdate(stored, issue) {
+		issue.Fields.Comment = stored.Fields.Comment
+	}
+	contents, err := json.Marshal(issue)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(issuesBucket).Put([]byte(issue.Key), contents); err != nil {
+		return err
+	}
+	return indexIssue(tx, issue)
+}
+
+func sameUpdate(a, b jira.JiraIssue) bool {
+	if a.Fields.Updated == nil || b.Fields.Updated == nil {
+		return false
+	}
+	return time.Time(*a.Fields.Updated).Equal(time.Time(*b.Fields.Updated))
 }
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/trevor-atlas/zilla/jira"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// terms splits text into the lowercase words it is indexed and searched by
+func terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(words))
+	var unique []string
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			unique = append(unique, word)
+		}
+	}
+	return unique
+}
+
+// issueText is everything an issue can be found by: its key, summary, description and comments
+func issueText(issue jira.JiraIssue) string {
+	parts := []string{issue.Key, issue.Fields.Summary, issue.Fields.Description}
+	for _, comment := range issue.Fields.Comment.Comments {
+		parts = append(parts, comment.Body)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func indexKey(term, issueKey string) []byte {
+	return []byte(term + "\x00" + issueKey)
+}
+
+// indexIssue replaces the index entries for an issue with ones for its current text
+func indexIssue(tx *bolt.Tx, issue jira.JiraIssue) error {
+	index := tx.Bucket(indexBucket)
+	termsByIssue := tx.Bucket(termsBucket)
+
+	var previous []string
+	if contents := termsByIssue.Get([]byte(issue.Key)); contents != nil {
+		if err := json.Unmarshal(contents, &previous); err != nil {
+			return err
+		}
+	}
+	for _, term := range previous {
+		if err := index.Delete(indexKey(term, issue.Key)); err != nil {
+			return err
+		}
+	}
+
+	current := terms(issueText(issue))
+	for _, term := range current {
+		if err := index.Put(indexKey(term, issue.Key), nil); err != nil {
+			return err
+		}
+	}
+	contents, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return termsByIssue.Put([]byte(issue.Key), contents)
+}
+
+// matching returns the keys of the issues with a word starting with prefix
+func matching(index *bolt.Bucket, prefix string) map[string]bool {
+	keys := make(map[string]bool)
+	c := index.Cursor()
+	for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+		if sep := bytes.IndexByte(k, 0); sep != -1 {
+			keys[string(k[sep+1:])] = true
+		}
+	}
+	return keys
+}
+
+// Search finds synced issues containing every word of the query in their key, summary,
+// description or comments. The last word of the query can be partial ("deplo" finds
+// "deployment"). Results are ordered most recently updated first.
+func (c *Cache) Search(text string) ([]jira.JiraIssue, error) {
+	words := terms(text)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	var issues []jira.JiraIssue
+	err := c.view(func(tx *bolt.Tx) error {
+		index := tx.Bucket(indexBucket)
+		if index == nil {
+			return nil
+		}
+		var found map[string]bool
+		for i, word := range words {
+			prefix := word + "\x00"
+			if i == len(words)-1 {
+				prefix = word
+			}
+			keys := matching(index, prefix)
+			if found == nil {
+				found = keys
+				continue
+			}
+			for key := range found {
+				if !keys[key] {
+					delete(found, key)
+				}
+			}
+		}
+		for key := range found {
+			if issue, ok := getIssue(tx, key); ok {
+				issues = append(issues, issue)
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(issues, func(i, j int) bool {
+		return updatedAt(issues[i]).After(updatedAt(issues[j]))
+	})
+	return issues, err
+}
+
+// Issues returns every synced issue, most recently updated first
+func (c *Cache) Issues() ([]jira.JiraIssue, error) {
+	var issues []jira.JiraIssue
+	err := c.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(issuesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var issue jira.JiraIssue
+			if err := json.Unmarshal(v, &issue); err != nil {
+				return err
+			}
+			issues = append(issues, issue)
+			return nil
+		})
+	})
+
+	sort.Slice(issues, func(i, j int) bool {
+		return updatedAt(issues[i]).After(updatedAt(issues[j]))
+	})
+	return issues, err
+}
+
+func updatedAt(issue jira.JiraIssue) time.Time {
+	if issue.Fields.Updated == nil {
+		return time.Time{}
+	}
+	return time.Time(*issue.Fields.Updated)
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/util"
+	"sync"
+	"time"
+)
+
+// ErrQueued is returned for a change made while offline. It has been saved
+// to the outbox and will be sent the next time the outbox is flushed.
+var ErrQueued = errors.New("offline, the change has been queued")
+
+// OfflineService wraps a jira client so zilla keeps working without a connection.
+// Issues, transitions and edit screens are served from the local database, and
+// comments, transitions and field edits are queued in the outbox until Flush is
+// called with the connection back. Adding a comment and transitioning aren't
+// safe to send twice, so they're only queued when they never left the machine.
+type OfflineService struct {
+	jira.ClientService
+	store *Cache
+	// flushing stops two flushes sending the same change
+	flushing sync.Mutex
+}
+
+func NewOfflineService(client jira.ClientService, store *Cache) *OfflineService {
+	return &OfflineService{ClientService: client, store: store}
+}
+
+func (s *OfflineService) GetIssue(ctx context.Context, issueNumber string) (*jira.JiraIssue, error) {
+	issue, err := s.ClientService.GetIssue(ctx, issueNumber)
+	if util.IsNetworkError(err) {
+		if stored, ok := s.store.Issue(issueNumber); ok {
+			return &stored, nil
+		}
+	}
+	return issue, err
+}
+
+func (s *OfflineService) GetTransitions(ctx context.Context, issueNumber string) ([]jira.Transition, error) {
+	key := "transitions/" + issueNumber
+	transitions, err := s.ClientService.GetTransitions(ctx, issueNumber)
+	if err == nil {
+		s.store.putResponse(key, transitions)
+	} else if util.IsNetworkError(err) && s.store.getResponse(key, &transitions) {
+		return transitions, nil
+	}
+	return transitions, err
+}
+
+func (s *OfflineService) GetEditMeta(ctx context.Context, issueNumber string) (map[string]jira.FieldMeta, error) {
+	key := "editmeta/" + issueNumber
+	fields, err := s.ClientService.GetEditMeta(ctx, issueNumber)
+	if err == nil {
+		s.store.putResponse(key, fields)
+	} else if util.IsNetworkError(err) && s.store.getResponse(key, &fields) {
+		return fields, nil
+	}
+	return fields, err
+}
+
+func (s *OfflineService) AddComment(ctx context.Context, issueNumber, body string) (*jira.IssueComment, error) {
+	comment, err := s.ClientService.AddComment(ctx, issueNumber, body)
+	if util.IsUnsent(err) {
+		// adding a comment can't clash with anything, so it isn't checked for conflicts
+		return nil, s.queue(QueuedChange{Kind: AddComment, Key: issueNumber, Body: body}, false)
+	}
+	return comment, err
+}
+
+func (s *OfflineService) UpdateComment(ctx context.Context, issueNumber, commentID, body string) (*jira.IssueComment, error) {
+	comment, err := s.ClientService.UpdateComment(ctx, issueNumber, commentID, body)
+	if util.IsNetworkError(err) {
+		return nil, s.queue(QueuedChange{Kind: EditComment, Key: issueNumber, CommentID: commentID, Body: body}, true)
+	}
+	return comment, err
+}
+
+func (s *OfflineService) DeleteComment(ctx context.Context, issueNumber, commentID string) error {
+	err := s.ClientService.DeleteComment(ctx, issueNumber, commentID)
+	if util.IsNetworkError(err) {
+		return s.queue(QueuedChange{Kind: DeleteComment, Key: issueNumber, CommentID: commentID}, true)
+	}
+	return err
+}
+
+func (s *OfflineService) DoTransition(ctx context.Context, issueNumber, transitionID string, fields map[string]interface{}) error {
+	err := s.ClientService.DoTransition(ctx, issueNumber, transitionID, fields)
+	if util.IsUnsent(err) {
+		return s.queue(QueuedChange{Kind: Transition, Key: issueNumber, TransitionID: transitionID, Fields: fields}, true)
+	}
+	return err
+}
+
+func (s *OfflineService) UpdateIssue(ctx context.Context, issueNumber string, fields map[string]interface{}) error {
+	err := s.ClientService.UpdateIssue(ctx, issueNumber, fields)
+	if util.IsNetworkError(err) {
+		return s.queue(QueuedChange{Kind: UpdateFields, Key: issueNumber, Fields: fields}, true)
+	}
+	return err
+}
+
+// queue saves a change to the outbox, returning ErrQueued once it is safely stored.
+// Changes that check for conflicts remember when the issue was last updated.
+func (s *OfflineService) queue(change QueuedChange, checkConflicts bool) error {
+	change.QueuedAt = time.Now()
+	if checkConflicts {
+		if issue, ok := s.store.Issue(change.Key); ok {
+			change.BaseUpdated = updatedAt(issue)
+		}
+	}
+	if err := s.store.Enqueue(change); err != nil {
+		return fmt.Errorf("offline, and the change couldn't be queued: %s", err)
+	}
+	return ErrQueued
+}
+
+// Flush sends the queued changes in the order they were made. Changes to issues that have
+// been updated on the server since, or that the server rejects, are marked as conflicts and
+// returned rather than sent. Flushing stops at the first network error, leaving the rest queued.
+// A comment or transition that may have reached the server before the error is marked as a
+// conflict too, for the user to check, rather than being sent again.
+func (s *OfflineService) Flush(ctx context.Context) (int, []QueuedChange, error) {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+	changes, err := s.store.Outbox()
+	if err != nil {
+		return 0, nil, err
+	}
+	sent := 0
+	var conflicts []QueuedChange
+	for _, change := range changes {
+		if change.Conflict != "" {
+			continue
+		}
+		if !change.BaseUpdated.IsZero() {
+			issue, err := s.ClientService.GetIssue(ctx, change.Key)
+			if err != nil {
+				if util.IsNetworkError(err) || ctx.Err() != nil {
+					return sent, conflicts, err
+				}
+				// the issue was deleted or can't be seen any more, which won't change by trying again
+				reason := fmt.Sprintf("couldn't check %s for changes: %s", change.Key, err)
+				if err := s.store.markConflict(change, reason); err != nil {
+					return sent, conflicts, err
+				}
+				change.Conflict = reason
+				conflicts = append(conflicts, change)
+				continue
+			}
+			if updatedAt(*issue).After(change.BaseUpdated) {
+				reason := fmt.Sprintf("%s was changed on the server after this was queued", change.Key)
+				if err := s.store.markConflict(change, reason); err != nil {
+					return sent, conflicts, err
+				}
+				change.Conflict = reason
+				conflicts = append(conflicts, change)
+				continue
+			}
+		}
+		if err := s.send(ctx, change); err != nil {
+			if util.IsNetworkError(err) && !util.IsUnsent(err) && !change.Kind.idempotent() {
+				// it may have gone through, sending it again could comment twice
+				reason := fmt.Sprintf("the connection dropped after %s was sent, check whether it was made before retrying it", change.Describe())
+				if err := s.store.markConflict(change, reason); err != nil {
+					return sent, conflicts, err
+				}
+				change.Conflict = reason
+				return sent, append(conflicts, change), err
+			}
+			if util.IsNetworkError(err) {
+				return sent, conflicts, err
+			}
+			// the server turned it down, eg: the transition isn't available from the issue's new status
+			if err := s.store.markConflict(change, err.Error()); err != nil {
+				return sent, conflicts, err
+			}
+			change.Conflict = err.Error()
+			conflicts = append(conflicts, change)
+			continue
+		}
+		if err := s.store.sent(change); err != nil {
+			return sent, conflicts, err
+		}
+		sent++
+	}
+	return sent, conflicts, nil
+}
+
+func (s *OfflineService) send(ctx context.Context, change QueuedChange) error {
+	var err error
+	switch change.Kind {
+	case AddComment:
+		_, err = s.ClientService.AddComment(ctx, change.Key, change.Body)
+	case EditComment:
+		_, err = s.ClientService.UpdateComment(ctx, change.Key, change.CommentID, change.Body)
+	case DeleteComment:
+		err = s.ClientService.DeleteComment(ctx, change.Key, change.CommentID)
+	case Transition:
+		err = s.ClientService.DoTransition(ctx, change.Key, change.TransitionID, change.Fields)
+	case UpdateFields:
+		err = s.ClientService.UpdateIssue(ctx, change.Key, change.Fields)
+	default:
+		err = fmt.Errorf("unknown change %q", change.Kind)
+	}
+	return err
+}
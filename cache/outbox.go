@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"strings"
+	"time"
+)
+
+var (
+	outboxBucket    = []byte("outbox")    // change id -> queued change
+	responsesBucket = []byte("responses") // the last transitions and edit screens seen for each issue
+)
+
+// ChangeKind is the sort of write a queued change will make
+type ChangeKind string
+
+const (
+	AddComment    ChangeKind = "comment"
+	EditComment   ChangeKind = "edit-comment"
+	DeleteComment ChangeKind = "delete-comment"
+	Transition    ChangeKind = "transition"
+	UpdateFields  ChangeKind = "update"
+)
+
+// idempotent reports whether sending the change twice does no more than sending it once
+func (k ChangeKind) idempotent() bool {
+	return k != AddComment && k != Transition
+}
+
+// QueuedChange is a write made while offline, waiting in the outbox to be sent
+type QueuedChange struct {
+	ID           uint64                 `json:"id"`
+	Kind         ChangeKind             `json:"kind"`
+	Key          string                 `json:"key"`
+	CommentID    string                 `json:"commentId,omitempty"`
+	TransitionID string                 `json:"transitionId,omitempty"`
+	Body         string                 `json:"body,omitempty"`
+	Fields       map[string]interface{} `json:"fields,omitempty"`
+	QueuedAt     time.Time              `json:"queuedAt"`
+	// BaseUpdated is when the issue had last been updated as far as we knew when the
+	// change was made. If the issue has been updated since, the change conflicts.
+	BaseUpdated time.Time `json:"baseUpdated"`
+	// Conflict is why the change couldn't be sent. Conflicting changes stay in the
+	// outbox, skipped, until they are retried or dropped.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// Describe summarises the change for listing
+func (q QueuedChange) Describe() string {
+	switch q.Kind {
+	case AddComment:
+		return fmt.Sprintf("comment on %s", q.Key)
+	case EditComment:
+		return fmt.Sprintf("edit comment %s on %s", q.CommentID, q.Key)
+	case DeleteComment:
+		return fmt.Sprintf("delete comment %s on %s", q.CommentID, q.Key)
+	case Transition:
+		return fmt.Sprintf("transition %s", q.Key)
+	default:
+		fields := make([]string, 0, len(q.Fields))
+		for field := range q.Fields {
+			fields = append(fields, field)
+		}
+		return fmt.Sprintf("update %s on %s", strings.Join(fields, ", "), q.Key)
+	}
+}
+
+func changeID(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+func putChange(tx *bolt.Tx, change QueuedChange) error {
+	contents, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(outboxBucket).Put(changeID(change.ID), contents)
+}
+
+// Enqueue adds a change to the end of the outbox
+func (c *Cache) Enqueue(change QueuedChange) error {
+	return c.update(func(tx *bolt.Tx) error {
+		id, err := tx.Bucket(outboxBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		change.ID = id
+		return putChange(tx, change)
+	})
+}
+
+// Outbox returns the queued changes in the order they were made
+func (c *Cache) Outbox() ([]QueuedChange, error) {
+	var changes []QueuedChange
+	err := c.view(func(tx *bolt.Tx) error {
+		outbox := tx.Bucket(outboxBucket)
+		if outbox == nil {
+			return nil
+		}
+		return outbox.ForEach(func(k, v []byte) error {
+			var change QueuedChange
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			changes = append(changes, change)
+			return nil
+		})
+	})
+	return changes, err
+}
+
+// Drop removes a change from the outbox without sending it
+func (c *Cache) Drop(id uint64) error {
+	return c.update(func(tx *bolt.Tx) error {
+		outbox := tx.Bucket(outboxBucket)
+		if outbox.Get(changeID(id)) == nil {
+			return fmt.Errorf("there is no queued change %d", id)
+		}
+		return outbox.Delete(changeID(id))
+	})
+}
+
+// Retry clears a change's conflict so it is sent on the next flush, overwriting whatever changed on the server
+func (c *Cache) Retry(id uint64) error {
+	return c.update(func(tx *bolt.Tx) error {
+		contents := tx.Bucket(outboxBucket).Get(changeID(id))
+		if contents == nil {
+			return fmt.Errorf("there is no queued change %d", id)
+		}
+		var change QueuedChange
+		if err := json.Unmarshal(contents, &change); err != nil {
+			return err
+		}
+		change.Conflict = ""
+		change.BaseUpdated = time.Time{}
+		return putChange(tx, change)
+	})
+}
+
+// markConflict records why a change couldn't be sent
+func (c *Cache) markConflict(change QueuedChange, reason string) error {
+	change.Conflict = reason
+	return c.update(func(tx *bolt.Tx) error {
+		return putChange(tx, change)
+	})
+}
+
+// sent removes a change that has been made on the server. The issue's update time has
+// moved on because of it, so the changes queued after it for the same issue no longer
+// check against the time they were queued at.
+func (c *Cache) sent(change QueuedChange) error {
+	return c.update(func(tx *bolt.Tx) error {
+		outbox := tx.Bucket(outboxBucket)
+		if err := outbox.Delete(changeID(change.ID)); err != nil {
+			return err
+		}
+		var later []QueuedChange
+		err := outbox.ForEach(func(k, v []byte) error {
+			var queued QueuedChange
+			if err := json.Unmarshal(v, &queued); err != nil {
+				return err
+			}
+			if queued.Key == change.Key && queued.Conflict == "" {
+				queued.BaseUpdated = time.Time{}
+				later = append(later, queued)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, queued := range later {
+			if err := putChange(tx, queued); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// putResponse keeps a copy of an API response to fall back on while offline
+func (c *Cache) putResponse(key string, v interface{}) error {
+	contents, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(responsesBucket).Put([]byte(key), contents)
+	})
+}
+
+// getResponse reads back a response saved by putResponse, reporting whether there was one
+func (c *Cache) getResponse(key string, v interface{}) bool {
+	found := false
+	c.view(func(tx *bolt.Tx) error {
+		responses := tx.Bucket(responsesBucket)
+		if responses == nil {
+			return nil
+		}
+		if contents := responses.Get([]byte(key)); contents != nil {
+			found = json.Unmarshal(contents, v) == nil
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"github.com/trevor-atlas/zilla/jira"
+	"time"
+)
+
+// ReconcileInterval is how long incremental syncs are trusted before the cached
+// results are checked against the full list of issues matching the query.
+// Incremental syncs can't see issues that were deleted or stopped matching.
+var ReconcileInterval = time.Hour
+
+// Sync brings the cached results for a query up to date and returns them.
+// The first sync of a query fetches its first page. After that the issues
+// updated since the last sync are asked for, with a full reconciliation every
+// ReconcileInterval.
+//
+// The cached issues are always the first pages of the query, in order, so that
+// paging can carry on from the end of them. An issue that changed can have moved
+// within the results, or into or out of them, and only the server knows where it
+// sorts now, so when anything has changed the query is started over from its
+// first page.
+func (c *Cache) Sync(ctx context.Context, client jira.ClientService, jql string, pageSize int) (Entry, error) {
+	now := time.Now()
+	entry, ok := c.Get(jql)
+	var keys []string
+	reconciling := ok && now.Sub(entry.ReconciledAt) > ReconcileInterval
+	if reconciling {
+		var err error
+		keys, err = jira.SearchKeys(ctx, client, jql)
+		if err != nil {
+			return entry, err
+		}
+		ok = entry.reconcile(keys)
+	}
+	if ok {
+		since := entry.HighWater
+		if since.IsZero() {
+			since = entry.FetchedAt
+		}
+		changed, err := jira.SearchUpdatedSince(ctx, client, jql, since)
+		if err != nil {
+			return entry, err
+		}
+		ok = len(changed.Issues) == 0
+	}
+	if !ok {
+		page, err := jira.NewSearchIterator(client, jql, pageSize).Next(ctx)
+		if err != nil {
+			return entry, err
+		}
+		return c.modify(jql, func(Entry, bool) Entry {
+			fresh := Entry{Issues: *page, FetchedAt: now, ReconciledAt: now}
+			fresh.updateHighWater()
+			return fresh
+		})
+	}
+	// a page may have been cached while the server was being asked, so what
+	// the reconciliation found is applied to the entry as it is now
+	return c.modify(jql, func(current Entry, _ bool) Entry {
+		// an entry that doesn't line up is reconciled again, and started over, next time
+		if reconciling && current.reconcile(keys) {
+			current.ReconciledAt = now
+		}
+		current.FetchedAt = now
+		current.updateHighWater()
+		return current
+	})
+}
+
+// Append adds a further page of results for a query to the cache
+func (c *Cache) Append(jql string, page jira.JiraIssues) error {
+	_, err := c.modify(jql, func(entry Entry, _ bool) Entry {
+		seen := entry.keys()
+		for _, issue := range page.Issues {
+			if !seen[issue.Key] {
+				entry.Issues.Issues = append(entry.Issues.Issues, issue)
+			}
+		}
+		entry.Issues.Total = page.Total
+		entry.updateHighWater()
+		return entry
+	})
+	return err
+}
+
+func (e Entry) keys() map[string]bool {
+	keys := make(map[string]bool, len(e.Issues.Issues))
+	for _, issue := range e.Issues.Issues {
+		keys[issue.Key] = true
+	}
+	return keys
+}
+
+// reconcile drops cached issues that no longer match the query (deleted, or moved
+// out of it) and puts the rest back in the order the server returned them. It
+// returns false if what's left isn't the start of the query's results, when
+// an issue that isn't cached sorts among the cached ones.
+func (e *Entry) reconcile(keys []string) bool {
+	cached := make(map[string]jira.JiraIssue, len(e.Issues.Issues))
+	for _, issue := range e.Issues.Issues {
+		cached[issue.Key] = issue
+	}
+	issues := make([]jira.JiraIssue, 0, len(e.Issues.Issues))
+	for _, key := range keys {
+		if issue, ok := cached[key]; ok {
+			issues = append(issues, issue)
+		}
+	}
+	e.Issues.Issues = issues
+	e.Issues.Total = len(keys)
+	for i, issue := range issues {
+		if keys[i] != issue.Key {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Entry) updateHighWater() {
+	for _, issue := range e.Issues.Issues {
+		if updated := updatedAt(issue); updated.After(e.HighWater) {
+			e.HighWater = updated
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/trevor-atlas/zilla/auth"
+	"github.com/trevor-atlas/zilla/cache"
+	"github.com/trevor-atlas/zilla/dirs"
+	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/secrets"
+	"github.com/trevor-atlas/zilla/util"
+	"golang.org/x/term"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// command is a non-interactive subcommand, run in place of the TUI
+type command func(app *util.Zilla, service jira.ClientService, args []string) error
+
+var commands = map[string]command{
+	"auth":    authCommand,
+	"config":  configCommand,
+	"create":  createCommand,
+	"doctor":  doctorCommand,
+	"grep":    grepCommand,
+	"outbox":  outboxCommand,
+	"secrets": secretsCommand,
+	"setup":   setupCommand,
+}
+
+func runCommand(app *util.Zilla, service jira.ClientService, args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage()
+		return 2
+	}
+	if err := cmd(app, service, args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "usage: zilla [flags] [command]")
+	fmt.Fprintln(os.Stderr, "run without a command to start the interactive UI")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	fmt.Fprintln(os.Stderr, "flags:")
+	fmt.Fprintln(os.Stderr, "  --config=<file>   read and save the config in file, ZILLA_CONFIG does the same")
+	fmt.Fprintln(os.Stderr, "  --trace-http      log every request to jira, with credentials redacted, short for --log.traceHttp=true")
+	fmt.Fprintln(os.Stderr, "  --profile=<name>  use one of the [profiles] from the config, p switches in the UI")
+	fmt.Fprintln(os.Stderr, "  --<key>=<value>   override a setting from the config, --jira.orgname=acme say")
+	fmt.Fprintln(os.Stderr, "                    ZILLA_<KEY> variables do the same, ZILLA_JIRA_ORGNAME=acme")
+	fmt.Fprintln(os.Stderr, "                    zilla config show --sources lists every key")
+}
+
+// parseFlags reads the flags that come before the command, there is one for
+// every setting in the config and --config for the config file itself. It
+// returns the settings they override, by key, and the command with its arguments.
+func parseFlags(args []string) (map[string]string, []string, error) {
+	flags := flag.NewFlagSet("zilla", flag.ContinueOnError)
+	flags.Usage = usage
+	flags.Func("config", "the config file to use", func(file string) error {
+		dirs.SetConfigFile(file)
+		return nil
+	})
+	overrides := map[string]string{}
+	for _, key := range util.ConfigKeys() {
+		key := key
+		flags.Func(key.Name, "overrides "+key.Name, func(value string) error {
+			// check the value suits the setting now, rather than ignore it once the config is loaded
+			if err := new(util.ConfigData).Set(key.Name, value); err != nil {
+				return err
+			}
+			overrides[key.Name] = value
+			return nil
+		})
+	}
+	traceHTTP := flags.Bool("trace-http", false, "log every request to jira")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *traceHTTP {
+		overrides["log.traceHttp"] = "true"
+	}
+	return overrides, flags.Args(), nil
+}
+
+// createCommand files an issue from a template: zilla create --template bug.toml --summary "it's broken"
+func createCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	templatePath := flags.String("template", "", "TOML or YAML file describing the issue")
+	project := flags.String("project", "", "project key, overrides the template")
+	issueType := flags.String("type", "", "issue type, overrides the template")
+	summary := flags.String("summary", "", "summary, overrides the template")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *templatePath == "" {
+		return fmt.Errorf("create needs a --template")
+	}
+
+	tmpl, err := loadIssueTemplate(*templatePath)
+	if err != nil {
+		return err
+	}
+	if *project != "" {
+		tmpl.Project = *project
+	}
+	if *issueType != "" {
+		tmpl.Type = *issueType
+	}
+	if *summary != "" {
+		tmpl.Summary = *summary
+	}
+
+	ctx := context.Background()
+	fields, err := tmpl.createFields(ctx, service)
+	if err != nil {
+		return err
+	}
+	created, err := service.CreateIssue(ctx, fields)
+	if err != nil {
+		return err
+	}
+	app.Info.Printf("created %s from template %s", created.Key, *templatePath)
+	fmt.Println(created.Key)
+	return nil
+}
+
+// grepCommand searches every issue synced so far without touching the network: zilla grep flaky deploy
+func grepCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	flags := flag.NewFlagSet("grep", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	text := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("grep needs something to search for")
+	}
+
+	store, err := cache.Load(app.Config.Profile)
+	if err != nil {
+		return err
+	}
+	issues, err := store.Search(text)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, issue := range issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", issue.Key, issue.Fields.Status.Name, issue.Fields.Summary)
+	}
+	return w.Flush()
+}
+
+// outboxCommand lists and manages the changes queued while offline:
+//
+//	zilla outbox             list them
+//	zilla outbox send        send them now
+//	zilla outbox retry <id>  send a conflicting change anyway, on the next send
+//	zilla outbox drop <id>   throw a change away
+func outboxCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	store, err := cache.Load(app.Config.Profile)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		changes, err := store.Outbox()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, change := range changes {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", change.ID, change.QueuedAt.Format("Jan 2 15:04"), change.Describe(), change.Conflict)
+		}
+		return w.Flush()
+	}
+
+	switch args[0] {
+	case "send":
+		sent, conflicts, err := cache.NewOfflineService(service, store).Flush(context.Background())
+		fmt.Printf("sent %d changes\n", sent)
+		for _, change := range conflicts {
+			fmt.Printf("%d: could not send %s: %s\n", change.ID, change.Describe(), change.Conflict)
+		}
+		return err
+	case "retry", "drop":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: zilla outbox %s <id>", args[0])
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a queued change id", args[1])
+		}
+		if args[0] == "retry" {
+			return store.Retry(id)
+		}
+		return store.Drop(id)
+	}
+	return fmt.Errorf("unknown outbox command %q", args[0])
+}
+
+// authCommand checks and manages how zilla signs in to jira:
+//
+//	zilla auth status  show the auth type and who jira thinks you are
+//	zilla auth login   log in through the browser, for the oauth2 auth type
+//	zilla auth logout  forget the oauth2 token
+func authCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zilla auth status|login|logout")
+	}
+	conf := app.Config.Jira
+	credentials, err := auth.Load(app)
+	if err != nil {
+		return err
+	}
+	oauth, isOAuth := credentials.(*auth.OAuth2)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		if app.Config.Profile != "" {
+			fmt.Fprintf(w, "profile:\t%s\n", app.Config.Profile)
+		}
+		fmt.Fprintf(w, "type:\t%s\n", credentials.Type())
+		fmt.Fprintf(w, "site:\t%s\n", conf.SiteURL())
+		if plain := app.FileConfig.PlaintextSecrets(); len(plain) > 0 {
+			fmt.Fprintf(w, "secrets:\t%s in plain text, run zilla secrets migrate\n", strings.Join(plain, ", "))
+		}
+		if isOAuth {
+			if token := oauth.Token(); token != nil {
+				fmt.Fprintf(w, "token:\tfor %s, expires %s (refreshed automatically)\n", token.SiteURL, token.Expiry.Format(time.RFC1123))
+			} else {
+				fmt.Fprintf(w, "token:\tnone, run zilla auth login\n")
+			}
+		}
+		user, err := service.GetMyself(ctx)
+		if err != nil {
+			fmt.Fprintf(w, "user:\tunknown\n")
+			err = fmt.Errorf("unable to sign in: %s", describeError(err))
+		} else {
+			fmt.Fprintf(w, "user:\t%s <%s>\n", user.DisplayName, user.EmailAddress)
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+		return err
+	case "login":
+		if !isOAuth {
+			return fmt.Errorf("the %s auth type reads its credentials from the config, there's nothing to log in to", credentials.Type())
+		}
+		err := oauth.Login(ctx, conf.SiteURL(), func(url string) {
+			fmt.Printf("opening your browser to log in, if it doesn't open visit:\n\n%s\n\n", url)
+			util.OpenBrowser(url)
+		})
+		if err != nil {
+			return err
+		}
+		app.Info.Printf("logged in to %s with oauth2", conf.SiteURL())
+		fmt.Println("logged in")
+		return nil
+	case "logout":
+		if !isOAuth {
+			return fmt.Errorf("the %s auth type reads its credentials from the config, remove them there", credentials.Type())
+		}
+		return oauth.Logout()
+	}
+	return fmt.Errorf("unknown auth command %q", args[0])
+}
+
+// setupCommand asks where jira is and how to sign in to it, and saves the answers once jira accepts them
+func setupCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	_, err := runSetup(app)
+	return err
+}
+
+// secretsCommand manages the credentials kept in the secret store:
+//
+//	zilla secrets migrate        move credentials out of the config into the store
+//	zilla secrets set <name>     store a secret, read from the terminal or stdin
+//	zilla secrets delete <name>  remove a secret
+//
+// The config names a stored secret as secret:<name>, apikey = "secret:jira-apikey" say.
+func secretsCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: zilla secrets migrate|set <name>|delete <name>")
+	}
+	store, err := app.Secrets()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "migrate":
+		// only what's in the file is moved, settings from the environment or flags aren't saved anywhere
+		conf := *app.FileConfig
+		// the profiles are changed in place, copy them so the loaded config keeps its values if saving fails
+		profiles := make(map[string]*util.Jiraconf, len(conf.Profiles))
+		for name, p := range conf.Profiles {
+			profile := *p
+			profiles[name] = &profile
+		}
+		conf.Profiles = profiles
+		moved, err := conf.MigrateSecrets(store)
+		if len(moved) == 0 {
+			if err == nil {
+				fmt.Println("there are no credentials in the config to move")
+			}
+			return err
+		}
+		// whatever was moved before a failure is in the store now, save the config so it refers to it
+		if saveErr := app.SaveConfig(&conf); saveErr != nil {
+			return saveErr
+		}
+		app.Info.Printf("moved %s to the %s secret store", strings.Join(moved, ", "), store.Backend())
+		for _, setting := range moved {
+			fmt.Printf("moved %s to the %s secret store\n", setting, store.Backend())
+		}
+		fmt.Println("the old values may still be in backups or the history of a dotfiles repo, revoke them if so")
+		return err
+	case "set", "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: zilla secrets %s <name>", args[0])
+		}
+		if args[0] == "delete" {
+			return store.Delete(args[1])
+		}
+		value, err := readSecret(fmt.Sprintf("value for %s: ", args[1]))
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("%s can't be empty", args[1])
+		}
+		if err := store.Set(args[1], value); err != nil {
+			return err
+		}
+		fmt.Printf("stored %s in the %s secret store, use it in the config as %q\n", args[1], store.Backend(), secrets.Ref(args[1]))
+		return nil
+	}
+	return fmt.Errorf("unknown secrets command %q", args[0])
+}
+
+// readSecret asks for a secret on the terminal without echoing it, or reads it from stdin when that's piped
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		value, err := ioutil.ReadAll(os.Stdin)
+		return strings.TrimSpace(string(value)), err
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimSpace(string(value)), err
+}
+
+// configCommand shows the effective config, after the environment and flags have been applied:
+//
+//	zilla config show            print every setting
+//	zilla config show --sources  and where each one came from
+func configCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: zilla config show [--sources]")
+	}
+	flags := flag.NewFlagSet("config show", flag.ContinueOnError)
+	showSources := flags.Bool("sources", false, "show where each setting came from")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, key := range util.ConfigKeys() {
+		value, err := app.Config.Get(key.Name)
+		if err != nil {
+			return err
+		}
+		shown := configValue(key, value)
+		if *showSources {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, shown, app.Sources[key.Name])
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", key.Name, shown)
+		}
+	}
+	return w.Flush()
+}
+
+// configValue formats a setting for zilla config show, hiding credentials but not the names of stored secrets
+func configValue(key util.ConfigKey, value string) string {
+	if _, isRef := secrets.IsRef(value); key.Secret && value != "" && !isRef {
+		return "(redacted)"
+	}
+	if value == "" {
+		return `""`
+	}
+	return value
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/util"
+	"strings"
+)
+
+type commentAction int
+
+const (
+	replyToComment commentAction = iota
+	editComment
+	deleteComment
+)
+
+func (a commentAction) String() string {
+	switch a {
+	case replyToComment:
+		return "Reply to a comment on"
+	case editComment:
+		return "Edit a comment on"
+	default:
+		return "Delete a comment on"
+	}
+}
+
+// commentEdit is a comment waiting to be written in $EDITOR.
+// The program quits to hand the terminal over to the editor
+// and main starts it again with the result.
+type commentEdit struct {
+	key       string
+	commentID string // empty for a new comment
+	initial   string
+}
+
+// commentPicker is the overlay for choosing which comment to reply to, edit or delete
+type commentPicker struct {
+	key      string
+	action   commentAction
+	comments []jira.IssueComment
+	cursor   int
+	confirm  bool
+}
+
+type CommentSaved struct {
+	Err     error
+	Key     string
+	Deleted bool
+}
+
+func (m Model) saveComment(edit commentEdit, body string) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if edit.commentID == "" {
+			_, err = m.jiraClient.AddComment(m.background, edit.key, body)
+		} else {
+			_, err = m.jiraClient.UpdateComment(m.background, edit.key, edit.commentID, body)
+		}
+		return CommentSaved{Err: err, Key: edit.key}
+	}
+}
+
+func (m Model) removeComment(key, commentID string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.jiraClient.DeleteComment(m.background, key, commentID)
+		return CommentSaved{Err: err, Key: key, Deleted: true}
+	}
+}
+
+// startEditing suspends the TUI and opens the comment in $EDITOR. The answer to
+// a request in flight would be lost while the program is stopped, so it waits for them.
+func (m Model) startEditing(edit commentEdit) (Model, tea.Cmd) {
+	if m.fetchingMore || m.syncing {
+		return m, m.list.NewStatusMessage("Still loading issues, try again in a moment")
+	}
+	m.editing = &edit
+	return m, tea.Quit
+}
+
+// finishEditing takes the text saved in $EDITOR and posts it, unless it was left empty or unchanged
+func (m Model) finishEditing(text string, err error) Model {
+	edit := *m.editing
+	m.editing = nil
+	body := strings.TrimSpace(text)
+	switch {
+	case err != nil:
+		m.pending = m.list.NewStatusMessage(fmt.Sprintf("Could not open editor: %v", err))
+	case body == "" || body == strings.TrimSpace(edit.initial):
+		m.pending = m.list.NewStatusMessage("Comment discarded")
+	default:
+		m.pending = m.saveComment(edit, body)
+	}
+	return m
+}
+
+func (m Model) openCommentPicker(action commentAction) (Model, tea.Cmd) {
+	issue, ok := m.selectedIssue()
+	if !ok {
+		return m, nil
+	}
+	full, ok := m.details[issue.Key]
+	if !ok {
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Still loading comments for %s", issue.Key))
+	}
+	if len(full.Fields.Comment.Comments) == 0 {
+		return m, m.list.NewStatusMessage(fmt.Sprintf("%s has no comments", issue.Key))
+	}
+	m.comments = &commentPicker{
+		key:      issue.Key,
+		action:   action,
+		comments: full.Fields.Comment.Comments,
+	}
+	return m, nil
+}
+
+func (m Model) updateCommentPicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.comments
+	if p.confirm {
+		p.confirm = false
+		if msg.String() == "y" {
+			m.comments = nil
+			return m, m.removeComment(p.key, p.comments[p.cursor].ID)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.comments = nil
+	case "up", "k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "j":
+		if p.cursor < len(p.comments)-1 {
+			p.cursor++
+		}
+	case "enter":
+		comment := p.comments[p.cursor]
+		switch p.action {
+		case replyToComment:
+			m.comments = nil
+			return m.startEditing(commentEdit{key: p.key, initial: quoteComment(comment)})
+		case editComment:
+			m.comments = nil
+			return m.startEditing(commentEdit{key: p.key, commentID: comment.ID, initial: comment.Body})
+		case deleteComment:
+			p.confirm = true
+		}
+	}
+	return m, nil
+}
+
+// quoteComment prefills a reply with the original comment in jira's quote markup
+func quoteComment(c jira.IssueComment) string {
+	return fmt.Sprintf("%s wrote:\n{quote}\n%s\n{quote}\n\n", c.Author.DisplayName, strings.TrimSpace(c.Body))
+}
+
+func (p commentPicker) View() string {
+	var b strings.Builder
+	b.WriteString(overlayTitleStyle.Render(fmt.Sprintf("%s %s", p.action, p.key)))
+	b.WriteString("\n")
+
+	for i, c := range p.comments {
+		preview := strings.SplitN(strings.TrimSpace(c.Body), "\n", 2)[0]
+		line := fmt.Sprintf("%s · %s: %s", c.Author.DisplayName, formatTime(c.Created), preview)
+		b.WriteString(cursorLine(line, i == p.cursor))
+		b.WriteString("\n")
+	}
+
+	hint := "enter: select • esc: cancel"
+	if p.confirm {
+		hint = "delete this comment? y: yes • any other key: no"
+	}
+	b.WriteString(overlayHintStyle.Render(hint))
+	return overlayStyle.Render(b.String())
+}
+
+// editComments runs $EDITOR for a model that quit to write a comment
+func editComments(m Model) Model {
+	text, err := util.EditText(m.editing.initial, "zilla-comment-*.txt")
+	return m.finishEditing(text, err)
+}
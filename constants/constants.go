@@ -1,8 +1,14 @@
 package constants
 
 var (
-	CONFIG_DIR      = ".config/zilla"
-	CONFIG_FILENAME = "zilla.toml"
-	CACHE_FILENAME  = "cache.json"
-	LOG_FILENAME    = "log.txt"
+	// CONFIG_DIR is where everything was kept before zilla followed the XDG spec, see the dirs package
+	CONFIG_DIR       = ".config/zilla"
+	CONFIG_FILENAME  = "zilla.toml"
+	CACHE_FILENAME   = "cache.json"
+	DB_FILENAME      = "zilla.db"
+	LOG_FILENAME     = "log.txt"
+	TOKEN_FILENAME   = "token.json"
+	SECRETS_FILENAME = "secrets.age"
+	// APP_NAME names zilla's directory in each of the XDG base directories
+	APP_NAME = "zilla"
 )
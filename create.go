@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/jira"
+	"sort"
+	"strings"
+)
+
+type createStep int
+
+const (
+	chooseProject createStep = iota
+	loadingCreateMeta
+	chooseIssueType
+	fillFields
+	creatingIssue
+)
+
+// createForm is the overlay for filing a new issue. It walks through
+// picking a project and issue type, then asks for every field the
+// project's create metadata says is required.
+type createForm struct {
+	step      createStep
+	project   textinput.Model
+	meta      *jira.CreateMetaProject
+	cursor    int
+	issueType *jira.CreateMetaIssueType
+	fields    []fieldInput
+	field     int
+}
+
+type GotCreateMeta struct {
+	Err     error
+	Project *jira.CreateMetaProject
+}
+
+type IssueCreated struct {
+	Err error
+	Key string
+}
+
+func (m Model) fetchCreateMeta(projectKey string) tea.Cmd {
+	return func() tea.Msg {
+		project, err := m.jiraClient.GetCreateMeta(m.background, projectKey)
+		return GotCreateMeta{Err: err, Project: project}
+	}
+}
+
+func (m Model) createIssue(fields map[string]interface{}) tea.Cmd {
+	return func() tea.Msg {
+		created, err := m.jiraClient.CreateIssue(m.background, fields)
+		if err != nil {
+			return IssueCreated{Err: err}
+		}
+		return IssueCreated{Key: created.Key}
+	}
+}
+
+func (m Model) openCreateForm() (Model, tea.Cmd) {
+	t := textinput.New()
+	t.Placeholder = "ABC"
+	if issue, ok := m.selectedIssue(); ok {
+		t.SetValue(issue.Fields.Project.Key)
+	}
+	t.Focus()
+	m.create = &createForm{project: t}
+	return m, textinput.Blink
+}
+
+// createFields returns the inputs for an issue type: summary and description
+// first, then any other required fields that don't have a default
+func createFields(issueType *jira.CreateMetaIssueType) []fieldInput {
+	var inputs []fieldInput
+	for _, id := range []string{"summary", "description"} {
+		if meta, ok := issueType.Fields[id]; ok {
+			inputs = append(inputs, newFieldInput(id, meta))
+		}
+	}
+	var rest []string
+	for id, meta := range issueType.Fields {
+		switch id {
+		case "project", "issuetype", "summary", "description":
+			continue
+		}
+		if meta.Required && !meta.HasDefaultValue {
+			rest = append(rest, id)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		return issueType.Fields[rest[i]].Name < issueType.Fields[rest[j]].Name
+	})
+	for _, id := range rest {
+		inputs = append(inputs, newFieldInput(id, issueType.Fields[id]))
+	}
+	return inputs
+}
+
+func (m Model) updateCreateForm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	f := m.create
+	if msg.String() == "esc" {
+		m.create = nil
+		return m, nil
+	}
+
+	switch f.step {
+	case chooseProject:
+		if msg.String() == "enter" {
+			key := strings.ToUpper(strings.TrimSpace(f.project.Value()))
+			if key == "" {
+				return m, nil
+			}
+			f.step = loadingCreateMeta
+			return m, m.fetchCreateMeta(key)
+		}
+		var cmd tea.Cmd
+		f.project, cmd = f.project.Update(msg)
+		return m, cmd
+
+	case chooseIssueType:
+		switch msg.String() {
+		case "up", "k":
+			if f.cursor > 0 {
+				f.cursor--
+			}
+		case "down", "j":
+			if f.cursor < len(f.meta.IssueTypes)-1 {
+				f.cursor++
+			}
+		case "enter":
+			if len(f.meta.IssueTypes) == 0 {
+				return m, nil
+			}
+			f.issueType = &f.meta.IssueTypes[f.cursor]
+			f.fields = createFields(f.issueType)
+			f.step = fillFields
+		}
+		return m, nil
+
+	case fillFields:
+		if msg.String() == "enter" {
+			if ok, cmd := m.submitField(f.fields[f.field]); !ok {
+				return m, cmd
+			}
+			if f.field < len(f.fields)-1 {
+				f.field++
+				return m, nil
+			}
+			f.step = creatingIssue
+			return m, m.createIssue(f.values())
+		}
+		var cmd tea.Cmd
+		f.fields[f.field], cmd = f.fields[f.field].Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// values collects the form into the fields for CreateIssue, leaving out optional fields left blank
+func (f createForm) values() map[string]interface{} {
+	fields := map[string]interface{}{
+		"project":   map[string]string{"key": f.meta.Key},
+		"issuetype": map[string]string{"id": f.issueType.ID},
+	}
+	for _, input := range f.fields {
+		if input.Empty() {
+			continue
+		}
+		// every field was checked as it was submitted
+		fields[input.id], _ = input.Value()
+	}
+	return fields
+}
+
+func (f createForm) View() string {
+	var b strings.Builder
+	title := "Create an issue"
+	if f.meta != nil {
+		title = fmt.Sprintf("Create an issue in %s", f.meta.Name)
+	}
+	if f.issueType != nil {
+		title = fmt.Sprintf("Create a %s in %s", f.issueType.Name, f.meta.Name)
+	}
+	b.WriteString(overlayTitleStyle.Render(title))
+	b.WriteString("\n")
+
+	switch f.step {
+	case chooseProject:
+		b.WriteString(fmt.Sprintf("Project key\n%s", f.project.View()))
+	case loadingCreateMeta:
+		b.WriteString("loading project...")
+	case chooseIssueType:
+		b.WriteString("Issue type\n")
+		for i, t := range f.meta.IssueTypes {
+			b.WriteString(cursorLine(t.Name, i == f.cursor))
+			b.WriteString("\n")
+		}
+	case fillFields:
+		b.WriteString(fmt.Sprintf("(%d/%d) ", f.field+1, len(f.fields)))
+		b.WriteString(f.fields[f.field].View())
+	case creatingIssue:
+		b.WriteString("creating issue...")
+	}
+
+	b.WriteString(overlayHintStyle.Render("enter: next • esc: cancel • * required"))
+	return overlayStyle.Render(b.String())
+}
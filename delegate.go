@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/trevor-atlas/zilla/cache"
+	"github.com/trevor-atlas/zilla/jira"
+	"io"
+	"log"
+	"strings"
+)
+
+const ellipsis = "…"
+
+// item wraps a jira issue so it can be shown in a list.Model
+type item struct {
+	issue jira.JiraIssue
+}
+
+func (i item) Title() string       { return i.issue.Key }
+func (i item) Description() string { return i.issue.Fields.Summary }
+func (i item) FilterValue() string { return i.issue.Key + " " + i.issue.Fields.Summary }
+
+// indexFilter is the list's filter. It searches the local database rather than
+// the titles in the list, so an issue is found by its description and comments too.
+func indexFilter(store *cache.Cache, errLog *log.Logger) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		found, err := store.Search(term)
+		if err != nil {
+			errLog.Printf("error searching the issue cache: %s", err)
+			return nil
+		}
+		// a target is an item's FilterValue, which starts with the issue key
+		indexes := make(map[string]int, len(targets))
+		for i, target := range targets {
+			indexes[strings.SplitN(target, " ", 2)[0]] = i
+		}
+		ranks := make([]list.Rank, 0, len(found))
+		for _, issue := range found {
+			if i, ok := indexes[issue.Key]; ok {
+				ranks = append(ranks, list.Rank{Index: i})
+			}
+		}
+		return ranks
+	}
+}
+
+func issueItems(issues []jira.JiraIssue) []list.Item {
+	items := make([]list.Item, len(issues))
+	for i, issue := range issues {
+		items[i] = item{issue: issue}
+	}
+	return items
+}
+
+type issueDelegateStyles struct {
+	normal   lipgloss.Style
+	selected lipgloss.Style
+	dimmed   lipgloss.Style
+	key      lipgloss.Style
+	status   lipgloss.Style
+	assignee lipgloss.Style
+}
+
+func newIssueDelegateStyles() (s issueDelegateStyles) {
+	s.normal = lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{Light: "#1a1a1a", Dark: "#dddddd"}).
+		Padding(0, 0, 0, 2)
+	s.selected = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.AdaptiveColor{Light: "#F793FF", Dark: "#AD58B4"}).
+		Foreground(lipgloss.AdaptiveColor{Light: "#EE6FF8", Dark: "#EE6FF8"}).
+		Padding(0, 0, 0, 1)
+	s.dimmed = s.normal.Copy().
+		Foreground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"})
+	s.key = lipgloss.NewStyle().Bold(true)
+	s.status = lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{Light: "#0B7285", Dark: "#44EEFF"})
+	s.assignee = lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"})
+	return s
+}
+
+// issueDelegate renders an issue as two lines:
+// the key, status and assignee on top and the summary underneath
+type issueDelegate struct {
+	styles issueDelegateStyles
+}
+
+func newIssueDelegate() issueDelegate {
+	return issueDelegate{styles: newIssueDelegateStyles()}
+}
+
+func (d issueDelegate) Height() int                               { return 2 }
+func (d issueDelegate) Spacing() int                              { return 1 }
+func (d issueDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d issueDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(item)
+	if !ok {
+		return
+	}
+	s := d.styles
+	fields := i.issue.Fields
+
+	assignee := fields.Assignee.DisplayName
+	if assignee == "" {
+		assignee = "Unassigned"
+	}
+	header := fmt.Sprintf("%s  %s  %s",
+		s.key.Render(i.issue.Key),
+		s.status.Render(fields.Status.Name),
+		s.assignee.Render(assignee),
+	)
+	summary := fields.Summary
+
+	if m.Width() > 0 {
+		textwidth := uint(m.Width() - s.normal.GetPaddingLeft() - s.normal.GetPaddingRight())
+		header = truncate.StringWithTail(header, textwidth, ellipsis)
+		summary = truncate.StringWithTail(summary, textwidth, ellipsis)
+	}
+
+	style := s.normal
+	if m.FilterState() == list.Filtering && m.FilterValue() == "" {
+		style = s.dimmed
+	} else if index == m.Index() && m.FilterState() != list.Filtering {
+		style = s.selected
+	}
+
+	fmt.Fprintf(w, "%s\n%s", style.Render(header), style.Render(summary))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/trevor-atlas/zilla/jira"
+	"strings"
+	"time"
+)
+
+const timeLayout = "Jan 2, 2006 15:04"
+
+var (
+	detailTitleStyle   = lipgloss.NewStyle().Bold(true)
+	detailKeyStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#44EEFF")).Bold(true)
+	detailLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"})
+	detailHeadingStyle = lipgloss.NewStyle().
+				Bold(true).
+				Border(lipgloss.NormalBorder(), false, false, true, false).
+				MarginTop(1)
+	detailAuthorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#EE6FF8"))
+)
+
+// GotIssueDetail is sent when the full contents of an issue have been fetched
+type GotIssueDetail struct {
+	Err   error
+	Issue jira.JiraIssue
+}
+
+func (m Model) fetchIssueDetail(key string) tea.Cmd {
+	return func() tea.Msg {
+		issue, err := m.jiraClient.GetIssue(m.background, key)
+		if err != nil {
+			return GotIssueDetail{Err: err, Issue: jira.JiraIssue{Key: key}}
+		}
+		return GotIssueDetail{Issue: *issue}
+	}
+}
+
+// syncDetail points the detail pane at the selected issue when the selection has changed,
+// requesting the full issue the first time it is shown
+func (m *Model) syncDetail() tea.Cmd {
+	issue, ok := m.selectedIssue()
+	if !ok {
+		m.detailKey = ""
+		m.viewport.SetContent("")
+		return nil
+	}
+	if issue.Key == m.detailKey {
+		return nil
+	}
+	m.detailKey = issue.Key
+	m.renderDetail()
+	m.viewport.GotoTop()
+	if _, ok := m.details[issue.Key]; ok {
+		return nil
+	}
+	return m.fetchIssueDetail(issue.Key)
+}
+
+// renderDetail redraws the detail pane for the selected issue,
+// preferring the fully loaded issue over the search result when we have it
+func (m *Model) renderDetail() {
+	issue, ok := m.selectedIssue()
+	if !ok {
+		return
+	}
+	if full, ok := m.details[issue.Key]; ok {
+		issue = full
+	}
+	m.viewport.SetContent(renderIssueDetail(issue, m.viewport.Width))
+}
+
+func renderIssueDetail(issue jira.JiraIssue, width int) string {
+	f := issue.Fields
+	var b strings.Builder
+
+	wrap := func(s string) string {
+		if width <= 0 {
+			return s
+		}
+		return wordwrap.String(s, width)
+	}
+	row := func(pairs ...string) {
+		var cells []string
+		for i := 0; i+1 < len(pairs); i += 2 {
+			cells = append(cells, detailLabelStyle.Render(pairs[i]+": ")+orNone(pairs[i+1]))
+		}
+		b.WriteString(wrap(strings.Join(cells, "   ")))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(detailKeyStyle.Render(issue.Key))
+	b.WriteString("\n")
+	b.WriteString(detailTitleStyle.Render(wrap(f.Summary)))
+	b.WriteString("\n\n")
+
+	row("Status", f.Status.Name, "Priority", f.Priority.Name, "Type", f.IssueType.Name)
+	project := f.Project.Name
+	if f.Project.Key != "" {
+		project = fmt.Sprintf("%s (%s)", f.Project.Name, f.Project.Key)
+	}
+	row("Project", project)
+	row("Reporter", f.Reporter.DisplayName, "Assignee", orUnassigned(f.Assignee.DisplayName))
+	row("Created", formatTime(f.Created), "Updated", formatTime(f.Updated))
+	row("Labels", strings.Join(f.Labels, ", "))
+
+	if names := issue.CustomFieldNames(); len(names) > 0 {
+		b.WriteString(detailHeadingStyle.Render("Fields"))
+		b.WriteString("\n")
+		for _, name := range names {
+			value, err := issue.Custom(name)
+			if err != nil || value.String() == "" {
+				continue
+			}
+			row(name, value.String())
+		}
+	}
+
+	b.WriteString(detailHeadingStyle.Render("Description"))
+	b.WriteString("\n")
+	b.WriteString(wrap(orNone(strings.TrimSpace(f.Description))))
+	b.WriteString("\n")
+
+	comments := f.Comment.Comments
+	b.WriteString(detailHeadingStyle.Render(fmt.Sprintf("Comments (%d)", len(comments))))
+	b.WriteString("\n")
+	for _, c := range comments {
+		b.WriteString(detailAuthorStyle.Render(c.Author.DisplayName))
+		b.WriteString(detailLabelStyle.Render(" · " + formatTime(c.Created)))
+		b.WriteString("\n")
+		b.WriteString(wrap(strings.TrimSpace(c.Body)))
+		b.WriteString("\n\n")
+	}
+
+	return b.String()
+}
+
+func formatTime(t *jira.Time) string {
+	if t == nil {
+		return ""
+	}
+	return time.Time(*t).Local().Format(timeLayout)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return detailLabelStyle.Render("None")
+	}
+	return s
+}
+
+func orUnassigned(s string) string {
+	if s == "" {
+		return "Unassigned"
+	}
+	return s
+}
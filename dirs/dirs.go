@@ -0,0 +1,128 @@
+// Package dirs finds where zilla keeps its files, following the XDG base
+// directory spec: the config in $XDG_CONFIG_HOME/zilla, the issue database in
+// $XDG_CACHE_HOME/zilla and the log in $XDG_STATE_HOME/zilla.
+package dirs
+
+import (
+	"errors"
+	"github.com/trevor-atlas/zilla/constants"
+	"os"
+	"path/filepath"
+)
+
+// ConfigEnv names a config file to use in place of zilla.toml in the config directory
+const ConfigEnv = "ZILLA_CONFIG"
+
+// configFile is the config file given with --config, it wins over ConfigEnv
+var configFile string
+
+// SetConfigFile makes path the config file, for --config
+func SetConfigFile(path string) {
+	configFile = path
+}
+
+// ConfigFileGiven reports whether the config file was picked with --config or ZILLA_CONFIG
+func ConfigFileGiven() bool {
+	return configFile != "" || os.Getenv(ConfigEnv) != ""
+}
+
+// ConfigFile is the config file to load and save: the one given with --config
+// or ZILLA_CONFIG, or zilla.toml in the config directory
+func ConfigFile() (string, error) {
+	for _, file := range []string{configFile, os.Getenv(ConfigEnv)} {
+		if file != "" {
+			return filepath.Abs(file)
+		}
+	}
+	return ConfigPath(constants.CONFIG_FILENAME)
+}
+
+// ConfigPath is where a file that belongs with the config is kept. A file an
+// older zilla left in ~/.config/zilla is used from there until there's one in
+// the config directory, as moving it would break dotfiles that link to it.
+func ConfigPath(name string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+	if legacy, err := LegacyDir(); err == nil {
+		if _, err := os.Stat(filepath.Join(legacy, name)); err == nil {
+			return filepath.Join(legacy, name), nil
+		}
+	}
+	return file, nil
+}
+
+// ConfigDir is where the config and the encrypted secrets file are kept, ~/.config/zilla by default
+func ConfigDir() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// CacheDir is where the issue databases are kept, ~/.cache/zilla by default
+func CacheDir() (string, error) {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// StateDir is where the log is kept, ~/.local/state/zilla by default
+func StateDir() (string, error) {
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// LegacyDir is where everything was kept before zilla followed the XDG spec,
+// files found there are moved to their new home when they're first needed
+func LegacyDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("couldn't locate home directory")
+	}
+	return filepath.Join(home, constants.CONFIG_DIR), nil
+}
+
+// xdgDir is the zilla directory under the base directory named by env, or
+// under fallback in the home directory. The spec says a relative path in the
+// variable is to be ignored.
+func xdgDir(env, fallback string) (string, error) {
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, constants.APP_NAME), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("couldn't locate home directory")
+	}
+	return filepath.Join(home, fallback, constants.APP_NAME), nil
+}
+
+// Ensure creates dir, and any missing parents, readable only by the user
+func Ensure(dir string) error {
+	return os.MkdirAll(dir, 0700)
+}
+
+// Move moves a file left in the legacy directory to path, unless there's a
+// file at path already. It's fine for there to be nothing to move.
+func Move(legacy, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+	if err := Ensure(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := os.Rename(legacy, path); err == nil {
+		return nil
+	}
+	// the directories can be on different filesystems, which rename can't cross
+	contents, err := os.ReadFile(legacy)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		return err
+	}
+	return os.Remove(legacy)
+}
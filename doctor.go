@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/trevor-atlas/zilla/auth"
+	"github.com/trevor-atlas/zilla/cache"
+	"github.com/trevor-atlas/zilla/dirs"
+	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/logger"
+	"github.com/trevor-atlas/zilla/util"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// doctorTimeout is how long each check that talks to jira gets
+const doctorTimeout = 15 * time.Second
+
+// outcomes of a doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	// checkSkip is for a check that can't be run because an earlier one failed
+	checkSkip = "skip"
+)
+
+type checkResult struct {
+	name    string
+	outcome string
+	detail  string
+}
+
+// doctor runs the checks in order, each one can look at what the earlier ones found
+type doctor struct {
+	app     *util.Zilla
+	service jira.ClientService
+	results []checkResult
+}
+
+func (d *doctor) report(name, outcome, format string, args ...interface{}) {
+	d.results = append(d.results, checkResult{name: name, outcome: outcome, detail: fmt.Sprintf(format, args...)})
+}
+
+// failed reports whether any check with the given name failed or was skipped
+func (d *doctor) failed(name string) bool {
+	for _, result := range d.results {
+		if result.name == name && (result.outcome == checkFail || result.outcome == checkSkip) {
+			return true
+		}
+	}
+	return false
+}
+
+// doctorCommand checks everything zilla needs, from the config to signing in
+// to jira, and prints what passed and what didn't: zilla doctor
+func doctorCommand(app *util.Zilla, service jira.ClientService, args []string) error {
+	d := &doctor{app: app, service: service}
+	d.checkConfig()
+	d.checkDirectories()
+	d.checkCredentials()
+	d.checkNetwork()
+	d.checkJira()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	failures := 0
+	for _, result := range d.results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.outcome, result.name, result.detail)
+		if result.outcome == checkFail {
+			failures++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	app.Info.Printf("doctor found %d problems", failures)
+	switch failures {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("1 check failed")
+	}
+	return fmt.Errorf("%d checks failed", failures)
+}
+
+func (d *doctor) checkConfig() {
+	configPath, err := util.ConfigPath()
+	if err != nil {
+		d.report("config", checkFail, "%s", err)
+		return
+	}
+	switch {
+	case util.ConfigExists():
+		d.report("config", checkPass, "%s", configPath)
+	case d.app.Overridden():
+		d.report("config", checkPass, "no file, the settings come from the environment and flags")
+	default:
+		d.report("config", checkFail, "there is no %s, run zilla setup", configPath)
+		return
+	}
+	for _, problem := range d.app.Problems {
+		d.report("config", checkFail, "%s", problem)
+	}
+	if err := d.app.CheckProfile(); err != nil {
+		d.report("config", checkFail, "%s", err)
+	}
+
+	if plain := d.app.FileConfig.PlaintextSecrets(); len(plain) > 0 {
+		d.report("config", checkWarn, "%s in plain text, run zilla secrets migrate", strings.Join(plain, ", "))
+		if info, err := os.Stat(configPath); err == nil && info.Mode().Perm()&0077 != 0 {
+			d.report("config", checkWarn, "other users can read the credentials in it, chmod 600 %s", configPath)
+		}
+	}
+}
+
+// checkDirectories makes sure zilla can write its config, log and cache
+func (d *doctor) checkDirectories() {
+	configPath, _ := util.ConfigPath()
+	cachePath, err := cache.Path(d.app.Config.Profile)
+	if err != nil {
+		d.report("directories", checkFail, "%s", err)
+		return
+	}
+	logPath, err := logger.Path()
+	if err != nil {
+		d.report("directories", checkFail, "%s", err)
+		return
+	}
+	locations := []struct{ what, dir string }{
+		{"config", path.Dir(configPath)},
+		{"log", path.Dir(logPath)},
+		{"cache", path.Dir(cachePath)},
+	}
+	checked := map[string][]string{}
+	var order []string
+	for _, entry := range locations {
+		if _, ok := checked[entry.dir]; !ok {
+			order = append(order, entry.dir)
+		}
+		checked[entry.dir] = append(checked[entry.dir], entry.what)
+	}
+	for _, dir := range order {
+		name := strings.Join(checked[dir], ", ")
+		if err := writable(dir); err != nil {
+			d.report("directories", checkFail, "can't write the %s to %s: %s", name, dir, err)
+			continue
+		}
+		d.report("directories", checkPass, "%s in %s", name, dir)
+	}
+}
+
+// writable checks a file can be created in dir, a directory that doesn't exist yet is made
+func writable(dir string) error {
+	if err := dirs.Ensure(dir); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".zilla-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func (d *doctor) checkCredentials() {
+	if _, fatal := util.Fatal(d.app.Problems); fatal {
+		d.report("credentials", checkSkip, "the config file couldn't be read")
+		return
+	}
+	store, err := d.app.Secrets()
+	if err != nil {
+		d.report("credentials", checkFail, "can't open the secret store: %s", err)
+		return
+	}
+	credentials, err := auth.Load(d.app)
+	if err != nil {
+		d.report("credentials", checkFail, "%s", err)
+		return
+	}
+	if oauth, ok := credentials.(*auth.OAuth2); ok && oauth.Token() == nil {
+		d.report("credentials", checkFail, "oauth2 isn't logged in, run zilla auth login")
+		return
+	}
+	d.report("credentials", checkPass, "%s auth, secrets kept in the %s store", credentials.Type(), store.Backend())
+}
+
+// checkNetwork makes sure the jira site answers at all, before trying to sign in to it
+func (d *doctor) checkNetwork() {
+	site := d.app.Config.Jira.SiteURL()
+	if conf := d.app.Config.Jira; conf.Orgname == "" && conf.CustomDomain == "" {
+		d.report("network", checkSkip, "there's no jira site in the config")
+		return
+	}
+	if u, err := url.Parse(site); err != nil || u.Host == "" {
+		d.report("network", checkSkip, "%q isn't the address of a site", site)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, site, nil)
+	if err != nil {
+		d.report("network", checkFail, "%s", err)
+		return
+	}
+	start := time.Now()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		d.report("network", checkFail, "can't reach %s: %s", site, err)
+		return
+	}
+	res.Body.Close()
+	d.report("network", checkPass, "%s answered in %s", site, time.Since(start).Round(time.Millisecond))
+}
+
+// checkJira signs in to jira and asks what it's running
+func (d *doctor) checkJira() {
+	if d.failed("network") || d.failed("credentials") {
+		d.report("sign in", checkSkip, "needs the network and credentials checks to pass")
+		d.report("server", checkSkip, "needs the network and credentials checks to pass")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	user, err := d.service.GetMyself(ctx)
+	if err != nil {
+		d.report("sign in", checkFail, "%s", describeError(err))
+	} else {
+		d.report("sign in", checkPass, "as %s <%s>", user.DisplayName, user.EmailAddress)
+	}
+
+	info, err := d.service.GetServerInfo(ctx)
+	if err != nil {
+		d.report("server", checkFail, "%s", describeError(err))
+		return
+	}
+	d.report("server", checkPass, "jira %s %s at %s", info.DeploymentType, info.Version, info.BaseUrl)
+}
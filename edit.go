@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/jira"
+	"sort"
+	"strings"
+)
+
+type editableField struct {
+	id   string
+	meta jira.FieldMeta
+}
+
+// editForm is the overlay for changing a single field of an issue.
+// It lists the fields editmeta says can be set and opens the editor
+// that matches the chosen field's schema.
+type editForm struct {
+	key     string
+	issue   jira.JiraIssue
+	loading bool
+	fields  []editableField
+	cursor  int
+	input   *fieldInput
+}
+
+type GotEditMeta struct {
+	Err    error
+	Key    string
+	Fields map[string]jira.FieldMeta
+}
+
+type IssueUpdated struct {
+	Err   error
+	Key   string
+	Field string
+}
+
+func (m Model) fetchEditMeta(key string) tea.Cmd {
+	return func() tea.Msg {
+		fields, err := m.jiraClient.GetEditMeta(m.background, key)
+		return GotEditMeta{Err: err, Key: key, Fields: fields}
+	}
+}
+
+func (m Model) updateIssue(key string, field editableField, value interface{}) tea.Cmd {
+	return func() tea.Msg {
+		err := m.jiraClient.UpdateIssue(m.background, key, map[string]interface{}{field.id: value})
+		return IssueUpdated{Err: err, Key: key, Field: field.meta.Name}
+	}
+}
+
+func (m Model) openEditForm() (Model, tea.Cmd) {
+	issue, ok := m.selectedIssue()
+	if !ok {
+		return m, nil
+	}
+	if full, ok := m.details[issue.Key]; ok {
+		issue = full
+	}
+	m.edit = &editForm{key: issue.Key, issue: issue, loading: true}
+	return m, m.fetchEditMeta(issue.Key)
+}
+
+// editableFields returns the fields that can be set, sorted by name
+func editableFields(fields map[string]jira.FieldMeta) []editableField {
+	var editable []editableField
+	for id, meta := range fields {
+		for _, op := range meta.Operations {
+			if op == "set" {
+				editable = append(editable, editableField{id: id, meta: meta})
+				break
+			}
+		}
+	}
+	sort.Slice(editable, func(i, j int) bool {
+		return editable[i].meta.Name < editable[j].meta.Name
+	})
+	return editable
+}
+
+// currentValue is the text to prefill an editor with for the fields we know how to read
+func currentValue(issue jira.JiraIssue, id string) string {
+	f := issue.Fields
+	switch id {
+	case "summary":
+		return f.Summary
+	case "description":
+		return f.Description
+	case "labels":
+		return strings.Join(f.Labels, ", ")
+	case "priority":
+		return f.Priority.Name
+	case "assignee":
+		return f.Assignee.DisplayName
+	case "reporter":
+		return f.Reporter.DisplayName
+	}
+	if value, err := issue.Custom(id); err == nil {
+		return value.String()
+	}
+	return ""
+}
+
+func (m Model) updateEditForm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	f := m.edit
+	if msg.String() == "esc" {
+		if f.input != nil && !f.loading {
+			f.input = nil
+			return m, nil
+		}
+		m.edit = nil
+		return m, nil
+	}
+	if f.loading {
+		return m, nil
+	}
+
+	if f.input == nil {
+		switch msg.String() {
+		case "up", "k":
+			if f.cursor > 0 {
+				f.cursor--
+			}
+		case "down", "j":
+			if f.cursor < len(f.fields)-1 {
+				f.cursor++
+			}
+		case "enter":
+			if len(f.fields) == 0 {
+				return m, nil
+			}
+			field := f.fields[f.cursor]
+			input := newFieldInput(field.id, field.meta)
+			input.SetValue(currentValue(f.issue, field.id))
+			f.input = &input
+		}
+		return m, nil
+	}
+
+	if msg.String() == "enter" {
+		if ok, cmd := m.submitField(*f.input); !ok {
+			return m, cmd
+		}
+		var value interface{}
+		if !f.input.Empty() {
+			value, _ = f.input.Value()
+		}
+		f.loading = true
+		return m, m.updateIssue(f.key, f.fields[f.cursor], value)
+	}
+	var cmd tea.Cmd
+	*f.input, cmd = f.input.Update(msg)
+	return m, cmd
+}
+
+func (f editForm) View() string {
+	var b strings.Builder
+	b.WriteString(overlayTitleStyle.Render(fmt.Sprintf("Edit %s", f.key)))
+	b.WriteString("\n")
+
+	switch {
+	case f.loading && f.input == nil:
+		b.WriteString("loading fields...")
+	case f.loading:
+		b.WriteString(fmt.Sprintf("saving %s...", f.input.meta.Name))
+	case f.input != nil:
+		b.WriteString(f.input.View())
+	case len(f.fields) == 0:
+		b.WriteString("you can't edit any fields on this issue")
+	default:
+		for i, field := range f.fields {
+			b.WriteString(cursorLine(field.meta.Name, i == f.cursor))
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString(overlayHintStyle.Render("enter: select • esc: back"))
+	return overlayStyle.Render(b.String())
+}
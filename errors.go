@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/trevor-atlas/zilla/auth"
+	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/util"
+)
+
+// errorHint suggests what to do about an error from jira, if there's anything to suggest
+func errorHint(err error) string {
+	switch {
+	case util.IsNetworkError(err):
+		return "check your connection"
+	case errors.Is(err, auth.ErrNotLoggedIn):
+		return "run zilla auth login"
+	case errors.Is(err, jira.ErrUnauthorized):
+		configPath, _ := util.ConfigPath()
+		return fmt.Sprintf("check the credentials in %s, zilla auth status shows what jira makes of them", configPath)
+	case errors.Is(err, jira.ErrForbidden):
+		return "your jira account doesn't have permission to do that"
+	case errors.Is(err, jira.ErrNotFound):
+		return "it may have been deleted or moved, or you can't see it"
+	case errors.Is(err, jira.ErrRateLimited):
+		return "jira is limiting requests, try again in a minute"
+	case errors.Is(err, jira.ErrInvalid):
+		return "check the values entered"
+	}
+	return ""
+}
+
+// describeError is an error's message followed by a hint on what to do about it
+func describeError(err error) string {
+	if hint := errorHint(err); hint != "" {
+		return fmt.Sprintf("%v (%s)", err, hint)
+	}
+	return err.Error()
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/trevor-atlas/zilla/jira"
+	"strings"
+	"time"
+)
+
+var (
+	overlayStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#AD58B4")).
+			Padding(1, 2)
+	overlayTitleStyle    = lipgloss.NewStyle().Bold(true).MarginBottom(1)
+	overlayHintStyle     = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"}).MarginTop(1)
+	overlaySelectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#EE6FF8"))
+)
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	optionField
+	multiOptionField
+	dateField
+	userField
+)
+
+// kindOf picks the editor for a field from its schema
+func kindOf(meta jira.FieldMeta) fieldKind {
+	switch {
+	case len(meta.AllowedValues) > 0 && meta.Schema.Type == "array":
+		return multiOptionField
+	case len(meta.AllowedValues) > 0:
+		return optionField
+	case meta.Schema.Type == "user":
+		return userField
+	case meta.Schema.Type == "date":
+		return dateField
+	default:
+		// strings, numbers and arrays of labels are all typed in
+		return textField
+	}
+}
+
+// fieldInput prompts for the value of a single jira field. Fields with allowed
+// values are picked from a list, users are searched for by name and everything
+// else is typed in.
+type fieldInput struct {
+	id       string
+	meta     jira.FieldMeta
+	kind     fieldKind
+	cursor   int
+	selected map[int]bool
+	text     textinput.Model
+	// users holds the results of the last user search and searched is the text it was for
+	users    []jira.IssueUser
+	searched string
+}
+
+func newFieldInput(id string, meta jira.FieldMeta) fieldInput {
+	t := textinput.New()
+	t.Focus()
+	f := fieldInput{id: id, meta: meta, kind: kindOf(meta), text: t, selected: make(map[int]bool)}
+	switch f.kind {
+	case dateField:
+		f.text.Placeholder = "YYYY-MM-DD"
+	case userField:
+		f.text.Placeholder = "search by name"
+	case textField:
+		if meta.Schema.Type == "array" {
+			f.text.Placeholder = "comma separated"
+		}
+	}
+	return f
+}
+
+// Empty reports whether the user has yet to provide a value
+func (f fieldInput) Empty() bool {
+	switch f.kind {
+	case optionField:
+		return false
+	case multiOptionField:
+		return len(f.selected) == 0
+	default:
+		return strings.TrimSpace(f.text.Value()) == ""
+	}
+}
+
+// Value returns the field value in the shape the jira API expects.
+// An empty, optional user field is nil, which clears it (eg: unassigns an issue).
+func (f fieldInput) Value() (interface{}, error) {
+	switch f.kind {
+	case optionField:
+		return map[string]string{"id": f.meta.AllowedValues[f.cursor].ID}, nil
+	case multiOptionField:
+		var options []interface{}
+		for i, v := range f.meta.AllowedValues {
+			if f.selected[i] {
+				options = append(options, map[string]string{"id": v.ID})
+			}
+		}
+		return options, nil
+	case userField:
+		if f.Empty() {
+			return nil, nil
+		}
+		if len(f.users) == 0 {
+			return nil, fmt.Errorf("no users match %q", f.text.Value())
+		}
+		return f.users[f.cursor].Ref(), nil
+	case dateField:
+		value := strings.TrimSpace(f.text.Value())
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, fmt.Errorf("%s must be a date like 2021-12-31", f.meta.Name)
+		}
+		return value, nil
+	default:
+		return f.meta.ValueFromString(f.text.Value())
+	}
+}
+
+// SetValue prefills the input with the field's current value
+func (f *fieldInput) SetValue(text string) {
+	f.text.SetValue(text)
+	f.text.CursorEnd()
+	for i, v := range f.meta.AllowedValues {
+		if v.Label() == text {
+			f.cursor = i
+		}
+	}
+}
+
+// needsSearch reports whether a user field's text has changed since the last search
+func (f fieldInput) needsSearch() bool {
+	return f.kind == userField && !f.Empty() && f.searched != strings.TrimSpace(f.text.Value())
+}
+
+func (f fieldInput) Update(msg tea.Msg) (fieldInput, tea.Cmd) {
+	key, isKey := msg.(tea.KeyMsg)
+	switch f.kind {
+	case optionField, multiOptionField:
+		if !isKey {
+			return f, nil
+		}
+		switch key.String() {
+		case "up", "k":
+			if f.cursor > 0 {
+				f.cursor--
+			}
+		case "down", "j":
+			if f.cursor < len(f.meta.AllowedValues)-1 {
+				f.cursor++
+			}
+		case " ":
+			if f.kind == multiOptionField {
+				if f.selected[f.cursor] {
+					delete(f.selected, f.cursor)
+				} else {
+					f.selected[f.cursor] = true
+				}
+			}
+		}
+		return f, nil
+	case userField:
+		if isKey && len(f.users) > 0 {
+			switch key.String() {
+			case "up":
+				if f.cursor > 0 {
+					f.cursor--
+				}
+				return f, nil
+			case "down":
+				if f.cursor < len(f.users)-1 {
+					f.cursor++
+				}
+				return f, nil
+			}
+		}
+	}
+	var cmd tea.Cmd
+	f.text, cmd = f.text.Update(msg)
+	return f, cmd
+}
+
+func (f fieldInput) View() string {
+	label := f.meta.Name
+	if f.meta.Required {
+		label += " *"
+	}
+	switch f.kind {
+	case optionField, multiOptionField:
+		options := make([]string, len(f.meta.AllowedValues))
+		for i, v := range f.meta.AllowedValues {
+			line := v.Label()
+			if f.kind == multiOptionField {
+				check := "[ ] "
+				if f.selected[i] {
+					check = "[x] "
+				}
+				line = check + line
+			}
+			options[i] = cursorLine(line, i == f.cursor)
+		}
+		if f.kind == multiOptionField {
+			label += " (space to toggle)"
+		}
+		return fmt.Sprintf("%s\n%s", label, strings.Join(options, "\n"))
+	case userField:
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("%s\n%s", label, f.text.View()))
+		for i, u := range f.users {
+			b.WriteString("\n")
+			b.WriteString(cursorLine(u.DisplayName, i == f.cursor))
+		}
+		if f.searched != "" && len(f.users) == 0 {
+			b.WriteString("\nno matching users")
+		}
+		return b.String()
+	default:
+		return fmt.Sprintf("%s\n%s", label, f.text.View())
+	}
+}
+
+// cursorLine renders a row of a picker, highlighting it when it is under the cursor
+func cursorLine(s string, selected bool) string {
+	if selected {
+		return overlaySelectedStyle.Render("> " + s)
+	}
+	return "  " + s
+}
+
+type GotUsers struct {
+	Err   error
+	Query string
+	Users []jira.IssueUser
+}
+
+func (m Model) findUsers(f fieldInput) tea.Cmd {
+	query := strings.TrimSpace(f.text.Value())
+	return func() tea.Msg {
+		users, err := m.jiraClient.FindUsers(m.background, f.meta.AutoCompleteURL, query)
+		return GotUsers{Err: err, Query: query, Users: users}
+	}
+}
+
+// submitField checks the value of a field when enter is pressed, reporting whether the
+// form can move on. User fields search for matching users the first time instead.
+func (m *Model) submitField(f fieldInput) (bool, tea.Cmd) {
+	if f.needsSearch() {
+		return false, m.findUsers(f)
+	}
+	if f.Empty() {
+		return !f.meta.Required, nil
+	}
+	if _, err := f.Value(); err != nil {
+		return false, m.list.NewStatusMessage(err.Error())
+	}
+	return true, nil
+}
+
+// activeField returns the field input currently taking input in whichever overlay is open
+func (m Model) activeField() *fieldInput {
+	switch {
+	case m.transitions != nil && len(m.transitions.fields) > 0:
+		return &m.transitions.fields[m.transitions.field]
+	case m.create != nil && len(m.create.fields) > 0:
+		return &m.create.fields[m.create.field]
+	case m.edit != nil && m.edit.input != nil:
+		return m.edit.input
+	}
+	return nil
+}
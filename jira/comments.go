@@ -0,0 +1,47 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+)
+
+type commentRequest struct {
+	Body string `json:"body"`
+}
+
+// AddComment posts a new comment to the issue
+func (s *Service) AddComment(ctx context.Context, issueNumber, body string) (*IssueComment, error) {
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/comment", s.baseUrl, issueNumber)
+	var comment IssueComment
+	_, err := s.request(ctx, url).
+		JSON(commentRequest{Body: body}).
+		Decode(&comment).
+		POST()
+	if err != nil {
+		return nil, fmt.Errorf("error making comment request: %w", apiError(err))
+	}
+	return &comment, nil
+}
+
+// UpdateComment replaces the body of an existing comment
+func (s *Service) UpdateComment(ctx context.Context, issueNumber, commentID, body string) (*IssueComment, error) {
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/comment/%s", s.baseUrl, issueNumber, commentID)
+	var comment IssueComment
+	_, err := s.request(ctx, url).
+		JSON(commentRequest{Body: body}).
+		Decode(&comment).
+		PUT()
+	if err != nil {
+		return nil, fmt.Errorf("error making comment request: %w", apiError(err))
+	}
+	return &comment, nil
+}
+
+// DeleteComment removes a comment from the issue
+func (s *Service) DeleteComment(ctx context.Context, issueNumber, commentID string) error {
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/comment/%s", s.baseUrl, issueNumber, commentID)
+	if _, err := s.request(ctx, url).DELETE(); err != nil {
+		return fmt.Errorf("error making comment request: %w", apiError(err))
+	}
+	return nil
+}
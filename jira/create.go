@@ -0,0 +1,95 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// CreateMetaProject describes the issue types a project can create and the fields each one takes
+type CreateMetaProject struct {
+	ID         string                `json:"id"`
+	Key        string                `json:"key"`
+	Name       string                `json:"name"`
+	IssueTypes []CreateMetaIssueType `json:"issuetypes"`
+}
+
+type CreateMetaIssueType struct {
+	ID      string               `json:"id"`
+	Name    string               `json:"name"`
+	Subtask bool                 `json:"subtask"`
+	Fields  map[string]FieldMeta `json:"fields"`
+}
+
+type createMetaResponse struct {
+	Projects []CreateMetaProject `json:"projects"`
+}
+
+// CreatedIssue is the response to creating an issue
+type CreatedIssue struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Self string `json:"self"`
+}
+
+type createIssueRequest struct {
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// GetCreateMeta returns the issue types that can be created in the project along with their fields
+func (s *Service) GetCreateMeta(ctx context.Context, projectKey string) (*CreateMetaProject, error) {
+	query := url.Values{}
+	query.Set("projectKeys", projectKey)
+	query.Set("expand", "projects.issuetypes.fields")
+	parsed := createMetaResponse{}
+	_, err := s.request(ctx, fmt.Sprintf("%s/rest/api/2/issue/createmeta?%s", s.baseUrl, query.Encode())).
+		Decode(&parsed).
+		GET()
+	if err != nil {
+		return nil, fmt.Errorf("error making createmeta request: %w", apiError(err))
+	}
+	if len(parsed.Projects) == 0 {
+		return nil, fmt.Errorf("project %s doesn't exist or you don't have permission to create issues in it", projectKey)
+	}
+	return &parsed.Projects[0], nil
+}
+
+// CreateIssue creates an issue from fields keyed by field id, eg:
+// { "project": { "key": "ABC" }, "issuetype": { "name": "Bug" }, "summary": "it's broken" }
+func (s *Service) CreateIssue(ctx context.Context, fields map[string]interface{}) (*CreatedIssue, error) {
+	parsed := CreatedIssue{}
+	res, err := s.request(ctx, fmt.Sprintf("%s/rest/api/2/issue", s.baseUrl)).
+		JSON(createIssueRequest{Fields: fields}).
+		Decode(&parsed).
+		POST()
+	if err != nil {
+		return nil, fmt.Errorf("error making create request: %w", apiError(err))
+	}
+	if parsed.Key == "" {
+		return nil, fmt.Errorf("unable to create issue: %s", res)
+	}
+	return &parsed, nil
+}
+
+// IssueType returns the create metadata for the named issue type
+func (p CreateMetaProject) IssueType(name string) (*CreateMetaIssueType, bool) {
+	for i, t := range p.IssueTypes {
+		if t.Name == name || t.ID == name {
+			return &p.IssueTypes[i], true
+		}
+	}
+	return nil, false
+}
+
+// FieldID resolves a field id or human readable field name to the field's id
+func (t CreateMetaIssueType) FieldID(name string) (string, bool) {
+	if _, ok := t.Fields[name]; ok {
+		return name, true
+	}
+	for id, f := range t.Fields {
+		if f.Name == name {
+			return id, true
+		}
+	}
+	return "", false
+}
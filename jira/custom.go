@@ -0,0 +1,299 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// knownFields are the json keys decoded into the IssueFields struct itself,
+// everything else is kept raw in IssueFields.Unknowns
+var knownFields = func() map[string]bool {
+	known := make(map[string]bool)
+	t := reflect.TypeOf(IssueFields{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+	return known
+}()
+
+// issueFields has the same shape as IssueFields without its json methods
+type issueFields IssueFields
+
+// UnmarshalJSON decodes the fields we know about and keeps every other field
+// (mostly customfield_*) as raw json so it can be decoded later with its schema
+func (f *IssueFields) UnmarshalJSON(b []byte) error {
+	var known issueFields
+	if err := json.Unmarshal(b, &known); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	*f = IssueFields(known)
+	for key, raw := range all {
+		if knownFields[strings.ToLower(key)] || string(raw) == "null" {
+			continue
+		}
+		if f.Unknowns == nil {
+			f.Unknowns = make(map[string]json.RawMessage)
+		}
+		f.Unknowns[key] = raw
+	}
+	return nil
+}
+
+// MarshalJSON writes the unknown fields back alongside the known ones
+// so that an issue survives a round trip through json
+func (f IssueFields) MarshalJSON() ([]byte, error) {
+	known, err := json.Marshal(issueFields(f))
+	if err != nil || len(f.Unknowns) == 0 {
+		return known, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(known, &all); err != nil {
+		return nil, err
+	}
+	for key, raw := range f.Unknowns {
+		all[key] = raw
+	}
+	return json.Marshal(all)
+}
+
+// keepFieldInfo copies the names and schemas of the issue's unknown fields from the
+// names and schema expansions, which the search endpoint returns once for every issue
+func (i *JiraIssue) keepFieldInfo(names map[string]string, schema map[string]FieldSchema) {
+	if len(i.Fields.Unknowns) == 0 {
+		i.Names = nil
+		i.Schema = nil
+		return
+	}
+	keptNames := make(map[string]string)
+	keptSchema := make(map[string]FieldSchema)
+	for id := range i.Fields.Unknowns {
+		if name, ok := names[id]; ok {
+			keptNames[id] = name
+		}
+		if s, ok := schema[id]; ok {
+			keptSchema[id] = s
+		}
+	}
+	i.Names = keptNames
+	i.Schema = keptSchema
+}
+
+// CustomFieldNames returns the names of the custom fields that have a value on this issue
+func (i JiraIssue) CustomFieldNames() []string {
+	var names []string
+	for id := range i.Fields.Unknowns {
+		if !strings.HasPrefix(id, "customfield_") {
+			continue
+		}
+		if name, ok := i.Names[id]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, id)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Custom decodes a field that isn't part of IssueFields, by display name
+// (Story Points) or id (customfield_10010), using the field's schema
+func (i JiraIssue) Custom(name string) (*CustomValue, error) {
+	id := name
+	if _, ok := i.Fields.Unknowns[id]; !ok {
+		id = ""
+		for fieldID, fieldName := range i.Names {
+			if fieldName == name {
+				id = fieldID
+				break
+			}
+		}
+	}
+	raw, ok := i.Fields.Unknowns[id]
+	if !ok {
+		return nil, fmt.Errorf("%s has no value for %q", i.Key, name)
+	}
+	return DecodeCustom(raw, i.Schema[id])
+}
+
+// CustomValue is a field value decoded according to its schema. Value holds
+// a float64, string, AllowedValue, IssueUser, Date, Time, Sprint, a slice of
+// those for array fields, or whatever encoding/json makes of anything else.
+type CustomValue struct {
+	Schema FieldSchema
+	Raw    json.RawMessage
+	Value  interface{}
+}
+
+const sprintSchema = "com.pyxis.greenhopper.jira:gh-sprint"
+
+// Sprint is an agile sprint, as held in the sprint custom field
+type Sprint struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// sprintString matches the toString() format older Jira Server versions use for sprints:
+// com.atlassian.greenhopper.service.sprint.Sprint@14b1c359[id=1,rapidViewId=1,state=ACTIVE,name=Sprint 1,...]
+var sprintString = regexp.MustCompile(`^[\w.]+@\w+\[(.*)\]$`)
+
+// sprintKey matches the start of each key=value pair in a sprint string. The
+// values aren't escaped, so a value runs up to the next ,key= and can hold commas.
+var sprintKey = regexp.MustCompile(`(?:^|,)(\w+)=`)
+
+// DecodeCustom decodes a raw field value according to its schema
+func DecodeCustom(raw json.RawMessage, schema FieldSchema) (*CustomValue, error) {
+	value, err := decodeValue(raw, schema.Type, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &CustomValue{Schema: schema, Raw: raw, Value: value}, nil
+}
+
+func decodeValue(raw json.RawMessage, kind string, schema FieldSchema) (interface{}, error) {
+	switch {
+	case kind == "array":
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			v, err := decodeValue(item, schema.Items, schema)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case schema.Custom == sprintSchema:
+		return decodeSprint(raw)
+	}
+
+	var err error
+	switch kind {
+	case "number":
+		var n float64
+		err = json.Unmarshal(raw, &n)
+		return n, err
+	case "string":
+		var s string
+		err = json.Unmarshal(raw, &s)
+		return s, err
+	case "option":
+		var v AllowedValue
+		err = json.Unmarshal(raw, &v)
+		return v, err
+	case "user":
+		var u IssueUser
+		err = json.Unmarshal(raw, &u)
+		return u, err
+	case "date":
+		var d Date
+		err = json.Unmarshal(raw, &d)
+		return d, err
+	case "datetime":
+		var t Time
+		err = json.Unmarshal(raw, &t)
+		return t, err
+	default:
+		var v interface{}
+		err = json.Unmarshal(raw, &v)
+		return v, err
+	}
+}
+
+func decodeSprint(raw json.RawMessage) (Sprint, error) {
+	var sprint Sprint
+	if err := json.Unmarshal(raw, &sprint); err == nil {
+		return sprint, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return sprint, err
+	}
+	match := sprintString.FindStringSubmatch(s)
+	if match == nil {
+		return sprint, fmt.Errorf("unrecognised sprint %q", s)
+	}
+	fields := parseSprintString(match[1])
+	id, err := strconv.Atoi(fields["id"])
+	if err != nil {
+		return sprint, fmt.Errorf("unrecognised sprint %q", s)
+	}
+	sprint.ID = id
+	sprint.State = fields["state"]
+	sprint.Name = fields["name"]
+	return sprint, nil
+}
+
+// parseSprintString splits the key=value pairs inside the brackets of a sprint string
+func parseSprintString(s string) map[string]string {
+	fields := map[string]string{}
+	keys := sprintKey.FindAllStringSubmatchIndex(s, -1)
+	for i, key := range keys {
+		end := len(s)
+		if i+1 < len(keys) {
+			end = keys[i+1][0]
+		}
+		fields[s[key[2]:key[3]]] = s[key[1]:end]
+	}
+	return fields
+}
+
+// String formats the value for display
+func (v CustomValue) String() string {
+	return formatValue(v.Value)
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case AllowedValue:
+		return v.Label()
+	case IssueUser:
+		return v.DisplayName
+	case Sprint:
+		return v.Name
+	case Date:
+		return time.Time(v).Format("Jan 2, 2006")
+	case Time:
+		return time.Time(v).Local().Format("Jan 2, 2006 15:04")
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, formatValue(item))
+		}
+		return strings.Join(parts, ", ")
+	case map[string]interface{}:
+		// objects without a schema we understand, like teams, usually have one of these
+		for _, key := range []string{"name", "value", "title", "displayName", "key"} {
+			if s, ok := v[key].(string); ok {
+				return s
+			}
+		}
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,131 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type editMetaResponse struct {
+	Fields map[string]FieldMeta `json:"fields"`
+}
+
+type updateIssueRequest struct {
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// GetEditMeta returns the fields of an issue that the current user can edit, keyed by field id
+func (s *Service) GetEditMeta(ctx context.Context, issueNumber string) (map[string]FieldMeta, error) {
+	parsed := editMetaResponse{}
+	_, err := s.request(ctx, fmt.Sprintf("%s/rest/api/2/issue/%s/editmeta", s.baseUrl, issueNumber)).
+		Decode(&parsed).
+		GET()
+	if err != nil {
+		return nil, fmt.Errorf("error making editmeta request: %w", apiError(err))
+	}
+	return parsed.Fields, nil
+}
+
+// UpdateIssue sets the given fields on an issue. Fields can be keyed by id or,
+// for custom fields, by their human readable name, eg:
+// { "summary": "new title", "Story Points": 5, "assignee": nil }
+func (s *Service) UpdateIssue(ctx context.Context, issueNumber string, fields map[string]interface{}) error {
+	resolved, err := s.resolveFieldNames(ctx, issueNumber, fields)
+	if err != nil {
+		return err
+	}
+	_, err = s.request(ctx, fmt.Sprintf("%s/rest/api/2/issue/%s", s.baseUrl, issueNumber)).
+		JSON(updateIssueRequest{Fields: resolved}).
+		PUT()
+	if err != nil {
+		return fmt.Errorf("error making update request: %w", apiError(err))
+	}
+	return nil
+}
+
+// resolveFieldNames swaps human readable custom field names for their ids. A
+// key is only taken as an id when it's a custom field id or a field in the
+// issue's edit metadata, so a custom field named in lower case, points say, is
+// still found by name. The server is only asked for what's needed to tell.
+func (s *Service) resolveFieldNames(ctx context.Context, issueNumber string, fields map[string]interface{}) (map[string]interface{}, error) {
+	var editable map[string]FieldMeta
+	var mapping map[string]string
+	resolved := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		if customFieldID.MatchString(name) {
+			resolved[name] = value
+			continue
+		}
+		if editable == nil {
+			meta, err := s.GetEditMeta(ctx, issueNumber)
+			if err != nil {
+				return nil, err
+			}
+			editable = meta
+		}
+		if _, ok := editable[name]; ok {
+			resolved[name] = value
+			continue
+		}
+		if mapping == nil {
+			mapped, err := s.GetMappedCustomFields(ctx)
+			if err != nil {
+				return nil, err
+			}
+			mapping = *mapped
+		}
+		id, ok := mapping[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		resolved[id] = value
+	}
+	return resolved, nil
+}
+
+// customFieldID matches the id of a custom field, customfield_10010
+var customFieldID = regexp.MustCompile(`^customfield_\d+$`)
+
+// FindUsers searches for users matching query. When autoCompleteURL is set
+// (from a field's edit metadata) it is used so that only users who can be
+// put in that field are returned, otherwise every user is searched.
+func (s *Service) FindUsers(ctx context.Context, autoCompleteURL, query string) ([]IssueUser, error) {
+	endpoint := s.onBaseURL(autoCompleteURL) + url.QueryEscape(query)
+	if autoCompleteURL == "" {
+		endpoint = fmt.Sprintf("%s/rest/api/2/user/search?query=%s", s.baseUrl, url.QueryEscape(query))
+	}
+	var users []IssueUser
+	if _, err := s.request(ctx, endpoint).Decode(&users).GET(); err != nil {
+		return nil, fmt.Errorf("error making user search request: %w", apiError(err))
+	}
+	return users, nil
+}
+
+// onBaseURL moves a url the api handed back onto the base url requests are sent
+// to. They name the site, but with oauth2 requests go through api.atlassian.com.
+func (s *Service) onBaseURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	// the base url already has the context path of a jira server, /jira say
+	endpoint := u.Path
+	if i := strings.Index(endpoint, "/rest/"); i >= 0 {
+		endpoint = endpoint[i:]
+	}
+	endpoint = s.baseUrl + endpoint
+	if u.RawQuery != "" {
+		endpoint += "?" + u.RawQuery
+	}
+	return endpoint
+}
+
+// Ref returns the reference used to put the user in a field
+func (u IssueUser) Ref() map[string]string {
+	if u.AccountId != "" {
+		return map[string]string{"accountId": u.AccountId}
+	}
+	return map[string]string{"name": u.Name}
+}
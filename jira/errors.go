@@ -0,0 +1,88 @@
+package jira
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/trevor-atlas/zilla/util"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized means jira didn't accept the credentials, they are missing, wrong or expired
+	ErrUnauthorized = errors.New("not logged in to jira")
+	// ErrForbidden means the credentials are fine but don't allow the request
+	ErrForbidden = errors.New("not allowed by jira")
+	// ErrNotFound means the issue (or whatever was asked for) doesn't exist or can't be seen
+	ErrNotFound = errors.New("not found in jira")
+	// ErrRateLimited means too many requests have been made, and the request should be tried again later
+	ErrRateLimited = errors.New("rate limited by jira")
+	// ErrInvalid means jira turned down what was sent, like a bad JQL query or a missing required field
+	ErrInvalid = errors.New("rejected by jira")
+)
+
+// APIError is an error response from the jira API, decoded from its
+// { "errorMessages": [...], "errors": { "field": "message" } } body.
+// It matches one of the Err variables with errors.Is.
+type APIError struct {
+	StatusCode int
+	Messages   []string
+	// Fields holds validation errors by field id
+	Fields map[string]string
+	kind   error
+}
+
+func (e *APIError) Error() string {
+	parts := append([]string(nil), e.Messages...)
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		parts = append(parts, fmt.Sprintf("%s: %s", field, e.Fields[field]))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s (%d %s)", e.kind, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// apiError turns an unsuccessful response into an APIError, anything else is returned as is
+func apiError(err error) error {
+	var statusErr *util.StatusError
+	if !errors.As(err, &statusErr) {
+		return err
+	}
+	apiErr := &APIError{StatusCode: statusErr.StatusCode}
+	var body struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	// some errors (rate limits, auth failures at the proxy) don't come with a json body
+	if json.Unmarshal(statusErr.Body, &body) == nil {
+		apiErr.Messages = body.ErrorMessages
+		apiErr.Fields = body.Errors
+	}
+	switch statusErr.StatusCode {
+	case http.StatusUnauthorized:
+		apiErr.kind = ErrUnauthorized
+	case http.StatusForbidden:
+		apiErr.kind = ErrForbidden
+	case http.StatusNotFound:
+		apiErr.kind = ErrNotFound
+	case http.StatusTooManyRequests:
+		apiErr.kind = ErrRateLimited
+	case http.StatusBadRequest:
+		apiErr.kind = ErrInvalid
+	default:
+		apiErr.kind = fmt.Errorf("jira responded %s", statusErr.Status)
+	}
+	return apiErr
+}
@@ -0,0 +1,78 @@
+package jira
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValueFromString converts text typed by a user into the shape the field
+// expects when creating or editing an issue, based on the field's schema.
+// Options are matched against the field's allowed values by name.
+func (m FieldMeta) ValueFromString(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch m.Schema.Type {
+	case "number":
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", m.Name)
+		}
+		return n, nil
+	case "array":
+		var values []interface{}
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			v, err := m.itemFromString(m.Schema.Items, part)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	default:
+		return m.itemFromString(m.Schema.Type, s)
+	}
+}
+
+func (m FieldMeta) itemFromString(kind, s string) (interface{}, error) {
+	if len(m.AllowedValues) > 0 {
+		for _, v := range m.AllowedValues {
+			if strings.EqualFold(v.Label(), s) || v.ID == s {
+				return map[string]string{"id": v.ID}, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a valid value for %s", s, m.Name)
+	}
+	switch kind {
+	case "project":
+		return map[string]string{"key": s}, nil
+	case "issuetype", "priority", "resolution", "component", "version", "option":
+		return map[string]string{"name": s}, nil
+	case "user":
+		return UserValue(s), nil
+	default:
+		return s, nil
+	}
+}
+
+// UserValue references a user the way the server expects. Jira Cloud identifies
+// users by account id (either 24 hex characters or prefixed like 557058:...)
+// while Server and Data Center use the username.
+func UserValue(s string) map[string]string {
+	if strings.Contains(s, ":") || (len(s) == 24 && isHex(s)) {
+		return map[string]string{"accountId": s}
+	}
+	return map[string]string{"name": s}
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package jira
 
 import (
+	"encoding/json"
+	"sort"
 	"time"
 )
 
@@ -22,6 +24,14 @@ type IssueUser struct {
 	Self         string
 }
 
+// ServerInfo describes a jira site, DeploymentType is Cloud or Server
+type ServerInfo struct {
+	BaseUrl        string `json:"baseUrl"`
+	Version        string `json:"version"`
+	DeploymentType string `json:"deploymentType"`
+	ServerTitle    string `json:"serverTitle"`
+}
+
 type IssueComment struct {
 	ID           string
 	Self         string
@@ -45,6 +55,9 @@ type IssueFields struct {
 	IssueType   IssueType
 	Status      IssueStatus
 	Project     IssueProject
+	Labels      []string `json:"labels"`
+	// Unknowns holds every other field (custom fields, mostly) as raw json, by field id
+	Unknowns map[string]json.RawMessage `json:"-"`
 }
 
 type IssueComments struct {
@@ -52,7 +65,7 @@ type IssueComments struct {
 }
 
 type IssuePriority struct {
-	Name string `json:"priority"` // Medium
+	Name string `json:"name"` // Medium
 }
 
 type IssueType struct {
@@ -82,10 +95,19 @@ type JiraIssue struct {
 	Self   string      `json:"self"` // url to request this issue
 	Key    string      `json:"key"`  // XYZ-1234
 	Fields IssueFields `json:"fields"`
+	// Names and Schema describe the fields in Fields.Unknowns, by field id
+	Names  map[string]string      `json:"names,omitempty"`
+	Schema map[string]FieldSchema `json:"schema,omitempty"`
 }
 
+// JiraIssues describes a single page of search results
 type JiraIssues struct {
-	Issues []JiraIssue
+	StartAt    int                    `json:"startAt"`
+	MaxResults int                    `json:"maxResults"`
+	Total      int                    `json:"total"`
+	Issues     []JiraIssue            `json:"issues"`
+	Names      map[string]string      `json:"names,omitempty"`
+	Schema     map[string]FieldSchema `json:"schema,omitempty"`
 }
 
 // UnmarshalJSON will transform the JIRA time into a time.Time
@@ -118,6 +140,16 @@ func (t *Date) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON writes the time back out in the format JIRA sends it in
+func (t Time) MarshalJSON() ([]byte, error) {
+	return []byte(time.Time(t).Format("\"2006-01-02T15:04:05.000-0700\"")), nil
+}
+
+// MarshalJSON writes the date back out in the format JIRA sends it in
+func (t Date) MarshalJSON() ([]byte, error) {
+	return []byte(time.Time(t).Format("\"2006-01-02\"")), nil
+}
+
 // Field represents a field of a Jira issue.
 type Field struct {
 	ID          string      `json:"id,omitempty" structs:"id,omitempty"`
@@ -139,3 +171,51 @@ type FieldSchema struct {
 	System   string `json:"system,omitempty" structs:"system,omitempty"`
 	CustomID int64  `json:"customId,omitempty" structs:"customId,omitempty"`
 }
+
+// FieldMeta describes how a field can be filled in on a transition, create or edit screen
+type FieldMeta struct {
+	Required        bool           `json:"required"`
+	HasDefaultValue bool           `json:"hasDefaultValue"`
+	Name            string         `json:"name"`
+	Key             string         `json:"key"`
+	Schema          FieldSchema    `json:"schema"`
+	AllowedValues   []AllowedValue `json:"allowedValues"`
+	Operations      []string       `json:"operations"`
+	AutoCompleteURL string         `json:"autoCompleteUrl"`
+}
+
+// AllowedValue is one of the options a field can be set to, like a resolution or priority
+type AllowedValue struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"` // custom field options use value instead of name
+}
+
+// Label returns the human readable text for the option
+func (v AllowedValue) Label() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return v.Value
+}
+
+// Transition is a move from an issue's current status to another status in its workflow
+type Transition struct {
+	ID        string               `json:"id"`
+	Name      string               `json:"name"` // Start Progress, In Review
+	To        IssueStatus          `json:"to"`
+	HasScreen bool                 `json:"hasScreen"`
+	Fields    map[string]FieldMeta `json:"fields"`
+}
+
+// RequiredFields returns the ids of the fields that must be filled in to perform the transition
+func (t Transition) RequiredFields() []string {
+	var ids []string
+	for id, f := range t.Fields {
+		if f.Required {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
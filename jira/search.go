@@ -0,0 +1,84 @@
+package jira
+
+import (
+	"context"
+)
+
+// SearchOptions controls which page of results a search returns
+// and, optionally, which fields are returned for each issue
+type SearchOptions struct {
+	StartAt    int
+	MaxResults int
+	Fields     []string
+}
+
+// SearchIterator walks every page of a JQL search, one request per page
+type SearchIterator struct {
+	client   ClientService
+	jql      string
+	pageSize int
+	fields   []string
+	startAt  int
+	total    int
+	started  bool
+}
+
+// NewSearchIterator returns an iterator over the results of jql.
+// A pageSize of 0 leaves the page size up to the server (usually 50).
+func NewSearchIterator(client ClientService, jql string, pageSize int) *SearchIterator {
+	return &SearchIterator{
+		client:   client,
+		jql:      jql,
+		pageSize: pageSize,
+	}
+}
+
+// HasNext reports whether there are pages left to fetch
+func (it *SearchIterator) HasNext() bool {
+	return !it.started || it.startAt < it.total
+}
+
+// SkipTo resumes the search after results that have already been loaded
+// (from a cache, say) so that the next page starts at startAt
+func (it *SearchIterator) SkipTo(startAt, total int) {
+	it.started = true
+	it.startAt = startAt
+	it.total = total
+}
+
+// Total is the number of issues matching the query, as reported by the last page
+func (it *SearchIterator) Total() int {
+	return it.total
+}
+
+// Next fetches the next page of results
+func (it *SearchIterator) Next(ctx context.Context) (*JiraIssues, error) {
+	page, err := it.client.Search(ctx, it.jql, &SearchOptions{StartAt: it.startAt, MaxResults: it.pageSize, Fields: it.fields})
+	if err != nil {
+		return nil, err
+	}
+	it.started = true
+	it.total = page.Total
+	it.startAt = page.StartAt + len(page.Issues)
+	if len(page.Issues) == 0 {
+		// the result set shrank underneath us, don't keep asking for pages that aren't there
+		it.total = it.startAt
+	}
+	return page, nil
+}
+
+// SearchAll walks every page of jql and returns all of the matching issues
+func SearchAll(ctx context.Context, client ClientService, jql string) (*JiraIssues, error) {
+	it := NewSearchIterator(client, jql, 0)
+	all := JiraIssues{}
+	for it.HasNext() {
+		page, err := it.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all.Issues = append(all.Issues, page.Issues...)
+	}
+	all.Total = it.Total()
+	all.MaxResults = len(all.Issues)
+	return &all, nil
+}
@@ -2,88 +2,155 @@ package jira
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"github.com/trevor-atlas/zilla/auth"
 	"github.com/trevor-atlas/zilla/util"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 type ClientService interface {
-	GetIssues(ctx context.Context) (*JiraIssues, error)
+	Search(ctx context.Context, jql string, opts *SearchOptions) (*JiraIssues, error)
 	GetIssue(ctx context.Context, issueNumber string) (*JiraIssue, error)
 	GetMappedCustomFields(ctx context.Context) (*map[string]string, error)
+	GetTransitions(ctx context.Context, issueNumber string) ([]Transition, error)
+	DoTransition(ctx context.Context, issueNumber, transitionID string, fields map[string]interface{}) error
+	AddComment(ctx context.Context, issueNumber, body string) (*IssueComment, error)
+	UpdateComment(ctx context.Context, issueNumber, commentID, body string) (*IssueComment, error)
+	DeleteComment(ctx context.Context, issueNumber, commentID string) error
+	GetCreateMeta(ctx context.Context, projectKey string) (*CreateMetaProject, error)
+	CreateIssue(ctx context.Context, fields map[string]interface{}) (*CreatedIssue, error)
+	GetEditMeta(ctx context.Context, issueNumber string) (map[string]FieldMeta, error)
+	UpdateIssue(ctx context.Context, issueNumber string, fields map[string]interface{}) error
+	FindUsers(ctx context.Context, autoCompleteURL, query string) ([]IssueUser, error)
+	GetMyself(ctx context.Context) (*IssueUser, error)
+	GetServerInfo(ctx context.Context) (*ServerInfo, error)
 }
 
 type Service struct {
 	config  util.ConfigData
-	client  util.RequestBuilder
 	baseUrl string
+	retry   util.RetryPolicy
+	auth    auth.Credentials
+	// trace logs each request when log.traceHttp is set, it's nil otherwise
+	trace func(util.Trace)
 }
 
 func NewService(application *util.Zilla) ClientService {
 	service := new(Service)
 	service.config = *application.Config
-	service.client = util.NewHTTP().
-		WithHeader("Accept", "application/json")
-	if service.config.Jira.CustomDomain != "" {
-		service.baseUrl = service.config.Jira.CustomDomain
-	} else {
-		service.baseUrl = fmt.Sprintf("https://%s.atlassian.net", service.config.Jira.Orgname)
+	credentials, err := auth.Load(application)
+	if err != nil {
+		application.Err.Printf("error setting up jira credentials: %s", err)
+		credentials = auth.Broken(err)
+	}
+	service.auth = credentials
+	if plain := application.FileConfig.PlaintextSecrets(); len(plain) > 0 {
+		application.Log.Warn("credentials in plain text, zilla secrets migrate moves them to the secret store", "settings", strings.Join(plain, ","))
 	}
-	if service.config.Jira.Apikey != "" {
-		service.client = service.client.WithBasicAuth(service.config.Jira.Username, service.config.Jira.Apikey)
-	} else {
-		service.client = service.client.WithHeader("Authorization", fmt.Sprintf("Bearer: %s", service.config.Jira.AccessToken))
+	service.baseUrl = credentials.BaseURL(service.config.Jira.SiteURL())
+	service.retry = service.config.HTTP.RetryPolicy()
+	service.retry.OnRetry = func(r util.Retry) {
+		application.Log.Warn("retrying request", "method", r.Method, "url", r.URL, "status", r.StatusCode, "wait", r.Wait, "attempt", r.Attempt)
+	}
+	if service.config.Log.TraceHTTP {
+		log := application.Log
+		service.trace = func(t util.Trace) {
+			fields := []interface{}{"method", t.Method, "url", t.URL, "status", t.StatusCode, "latency", t.Latency, "attempt", t.Attempt,
+				"request_headers", t.RequestHeader}
+			if t.ResponseHeader != nil {
+				fields = append(fields, "response_headers", t.ResponseHeader)
+			}
+			if t.Err != nil {
+				log.Info("http request failed", append(fields, "error", t.Err)...)
+				return
+			}
+			log.Info("http request", fields...)
+		}
 	}
 	return service
 }
 
-func (s *Service) GetIssues(ctx context.Context) (*JiraIssues, error) {
-	var url string
-	url = fmt.Sprintf("%s/rest/api/2/search?jql=assignee=currentuser()+order+by+status+asc&expand=fields", s.baseUrl)
-	client := s.client.Url(url)
+// request returns a new request builder for url with the credentials attached,
+// sent with ctx so that it stops when ctx is cancelled. Every call gets its own
+// builder, so requests from different goroutines don't share anything.
+func (s *Service) request(ctx context.Context, url string) util.RequestBuilder {
+	return util.NewHTTP().
+		WithTrace(s.trace).
+		WithContext(ctx).
+		WithRetry(s.retry).
+		WithAuth(s.auth).
+		Url(url).
+		WithHeader("Accept", "application/json")
+}
 
-	body, err := client.GET()
-	if err != nil {
-		return nil, fmt.Errorf("there was a problem making the request to the jira API in `GetIssues`: %s", err)
+// Search runs the given JQL query against the search endpoint and returns
+// the page of results described by opts, or the first page if opts is nil
+func (s *Service) Search(ctx context.Context, jql string, opts *SearchOptions) (*JiraIssues, error) {
+	query := url.Values{}
+	query.Set("jql", jql)
+	query.Set("expand", "names,schema")
+	if opts != nil {
+		query.Set("startAt", strconv.Itoa(opts.StartAt))
+		if opts.MaxResults > 0 {
+			query.Set("maxResults", strconv.Itoa(opts.MaxResults))
+		}
+		if len(opts.Fields) > 0 {
+			query.Set("fields", strings.Join(opts.Fields, ","))
+		}
 	}
+	client := s.request(ctx, fmt.Sprintf("%s/rest/api/2/search?%s", s.baseUrl, query.Encode()))
 
 	parsed := JiraIssues{}
-	parseError := json.Unmarshal(body, &parsed)
-	if parseError != nil {
-		return nil, fmt.Errorf("there was a problem parsing the jira API response:%s\n", parseError)
+	if _, err := client.Decode(&parsed).GET(); err != nil {
+		return nil, fmt.Errorf("there was a problem making the request to the jira API in `Search`: %w", apiError(err))
+	}
+	for i := range parsed.Issues {
+		parsed.Issues[i].keepFieldInfo(parsed.Names, parsed.Schema)
 	}
+	parsed.Names = nil
+	parsed.Schema = nil
 	return &parsed, nil
 }
 
 func (s *Service) GetIssue(ctx context.Context, issueNumber string) (*JiraIssue, error) {
-	url := fmt.Sprintf("%s/rest/api/2/issue/%s?expand=fields", s.baseUrl, issueNumber)
-	client := s.client.Url(url)
-
-	res, err := client.GET()
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %s", err)
-	}
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s?expand=names,schema", s.baseUrl, issueNumber)
+	client := s.request(ctx, url)
 
 	parsed := JiraIssue{}
-	parseError := json.Unmarshal(res, &parsed)
-	if parseError != nil {
-		return nil, fmt.Errorf("error parsing json: %s", parseError)
+	if _, err := client.Decode(&parsed).GET(); err != nil {
+		return nil, fmt.Errorf("error making request: %w", apiError(err))
 	}
+	parsed.keepFieldInfo(parsed.Names, parsed.Schema)
 	return &parsed, nil
 }
 
+// GetMyself returns the user the credentials belong to
+func (s *Service) GetMyself(ctx context.Context) (*IssueUser, error) {
+	user := IssueUser{}
+	if _, err := s.request(ctx, fmt.Sprintf("%s/rest/api/2/myself", s.baseUrl)).Decode(&user).GET(); err != nil {
+		return nil, fmt.Errorf("error making myself request: %w", apiError(err))
+	}
+	return &user, nil
+}
+
+// GetServerInfo returns the version and deployment of the jira site
+func (s *Service) GetServerInfo(ctx context.Context) (*ServerInfo, error) {
+	info := ServerInfo{}
+	if _, err := s.request(ctx, fmt.Sprintf("%s/rest/api/2/serverInfo", s.baseUrl)).Decode(&info).GET(); err != nil {
+		return nil, fmt.Errorf("error making serverInfo request: %w", apiError(err))
+	}
+	return &info, nil
+}
+
 func (s *Service) getFieldsList(ctx context.Context) ([]Field, error) {
 	url := fmt.Sprintf("%s/rest/api/2/field", s.baseUrl)
-	client := s.client.Url(url)
-	res, err := client.GET()
-	if err != nil {
-		return nil, fmt.Errorf("error making fields request")
-	}
+	client := s.request(ctx, url)
 
 	var fieldList []Field
-	parseError := json.Unmarshal(res, &fieldList)
-	if parseError != nil {
-		return nil, fmt.Errorf("error parsing fields json\n %s", parseError)
+	if _, err := client.Decode(&fieldList).GET(); err != nil {
+		return nil, fmt.Errorf("error making fields request: %w", apiError(err))
 	}
 	return fieldList, nil
 }
@@ -94,7 +161,7 @@ func (s *Service) getFieldsList(ctx context.Context) ([]Field, error) {
 func (s *Service) GetMappedCustomFields(ctx context.Context) (*map[string]string, error) {
 	fields, err := s.getFieldsList(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get the list of fields: %s", err)
+		return nil, fmt.Errorf("unable to get the list of fields: %w", err)
 	}
 
 	var fieldMapping = make(map[string]string)
@@ -0,0 +1,68 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// splitOrderBy separates the ORDER BY clause from a JQL query so more conditions can be added
+func splitOrderBy(jql string) (string, string) {
+	lower := strings.ToLower(jql)
+	inQuote := rune(0)
+	at := -1
+	for i, r := range lower {
+		switch {
+		case inQuote != 0:
+			if r == inQuote {
+				inQuote = 0
+			}
+		case r == '"' || r == '\'':
+			inQuote = r
+		case strings.HasPrefix(lower[i:], "order by") && (i == 0 || lower[i-1] == ' ' || lower[i-1] == ')'):
+			at = i
+		}
+	}
+	if at == -1 {
+		return strings.TrimSpace(jql), ""
+	}
+	return strings.TrimSpace(jql[:at]), strings.TrimSpace(jql[at:])
+}
+
+// UpdatedSinceJQL narrows a query to the issues updated since the given time.
+// The window is relative ("-15m") rather than a date, since JQL dates are read
+// in the timezone of the user's Jira profile, and it is rounded up to the
+// minute so nothing updated around the cutoff is missed.
+func UpdatedSinceJQL(jql string, since time.Time) string {
+	minutes := int(math.Ceil(time.Since(since).Minutes())) + 1
+	condition := fmt.Sprintf(`updated >= "-%dm"`, minutes)
+	where, orderBy := splitOrderBy(jql)
+	if where != "" {
+		condition = fmt.Sprintf("(%s) AND %s", where, condition)
+	}
+	return strings.TrimSpace(condition + " " + orderBy)
+}
+
+// SearchUpdatedSince returns every issue matching jql that has been updated since the given time
+func SearchUpdatedSince(ctx context.Context, client ClientService, jql string, since time.Time) (*JiraIssues, error) {
+	return SearchAll(ctx, client, UpdatedSinceJQL(jql, since))
+}
+
+// SearchKeys returns the key of every issue matching jql, in order, without fetching their fields
+func SearchKeys(ctx context.Context, client ClientService, jql string) ([]string, error) {
+	it := NewSearchIterator(client, jql, 0)
+	it.fields = []string{"key"}
+	var keys []string
+	for it.HasNext() {
+		page, err := it.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			keys = append(keys, issue.Key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,41 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+)
+
+type transitionsResponse struct {
+	Transitions []Transition `json:"transitions"`
+}
+
+type transitionRequest struct {
+	Transition struct {
+		ID string `json:"id"`
+	} `json:"transition"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// GetTransitions returns the transitions available from the issue's current status,
+// including the fields each transition's screen asks for
+func (s *Service) GetTransitions(ctx context.Context, issueNumber string) ([]Transition, error) {
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/transitions?expand=transitions.fields", s.baseUrl, issueNumber)
+	parsed := transitionsResponse{}
+	if _, err := s.request(ctx, url).Decode(&parsed).GET(); err != nil {
+		return nil, fmt.Errorf("error making transitions request: %w", apiError(err))
+	}
+	return parsed.Transitions, nil
+}
+
+// DoTransition moves the issue through the given transition.
+// fields holds values for the transition screen, eg: { "resolution": { "name": "Done" } }
+func (s *Service) DoTransition(ctx context.Context, issueNumber, transitionID string, fields map[string]interface{}) error {
+	payload := transitionRequest{Fields: fields}
+	payload.Transition.ID = transitionID
+
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/transitions", s.baseUrl, issueNumber)
+	if _, err := s.request(ctx, url).JSON(payload).POST(); err != nil {
+		return fmt.Errorf("error making transition request: %w", apiError(err))
+	}
+	return nil
+}
@@ -1,24 +1,303 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/trevor-atlas/zilla/constants"
+	"github.com/trevor-atlas/zilla/dirs"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-var CommonLog *log.Logger
-var ErrorLog *log.Logger
+// Level is how much a record matters, records below the level the logger is set to aren't written
+type Level int
 
-func GetLoggers() (*log.Logger, *log.Logger) {
-	home, err := os.UserHomeDir()
-	openLogfile, err := os.OpenFile(path.Join(home, constants.CONFIG_DIR, constants.LOG_FILENAME), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel reads a level as it's written in the config, debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+// Formats a record can be written in
+const (
+	// FormatLogfmt writes a record as key=value pairs on a line
+	FormatLogfmt = "logfmt"
+	// FormatJSON writes a record as a json object on a line
+	FormatJSON = "json"
+)
+
+// Options are the settings from the log section of the config
+type Options struct {
+	Level  Level
+	Format string
+	// MaxSize is how many bytes the log grows to before it's rotated
+	MaxSize int64
+	// MaxFiles is how many rotated logs are kept, log.txt.1 is the newest
+	MaxFiles int
+}
+
+// DefaultOptions is how the log is written until the config says otherwise
+var DefaultOptions = Options{Level: LevelInfo, Format: FormatLogfmt, MaxSize: 5 << 20, MaxFiles: 3}
+
+// Logger writes leveled records with fields to the log file, which is rotated
+// once it gets too big. It's safe to use from any number of goroutines.
+type Logger struct {
+	mu   sync.Mutex
+	out  io.Writer
+	file *rotatingFile
+	opts Options
+}
+
+// Path is where the log is written, $XDG_STATE_HOME/zilla/log.txt
+func Path() (string, error) {
+	dir, err := dirs.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, constants.LOG_FILENAME), nil
+}
+
+// New opens the log with DefaultOptions, creating the state directory if this is the first run
+func New() *Logger {
+	l := &Logger{out: io.Discard, opts: DefaultOptions}
+	file, err := openLog(DefaultOptions)
 	if err != nil {
-		fmt.Println("Error opening logfile:", err)
-		os.Exit(1)
+		// zilla works without a log, it's not worth stopping over
+		fmt.Fprintln(os.Stderr, "Error opening logfile:", err)
+		return l
+	}
+	l.file = file
+	l.out = file
+	return l
+}
+
+func openLog(opts Options) (*rotatingFile, error) {
+	logPath, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if err := dirs.Ensure(path.Dir(logPath)); err != nil {
+		return nil, err
+	}
+	// an older zilla logged to ~/.config/zilla, that log is carried on with,
+	// or dropped if there's one in the state directory already
+	if legacyDir, err := dirs.LegacyDir(); err == nil {
+		legacy := path.Join(legacyDir, constants.LOG_FILENAME)
+		if err := dirs.Move(legacy, logPath); err == nil {
+			os.Remove(legacy)
+		}
+	}
+	return openRotating(logPath, opts.MaxSize, opts.MaxFiles)
+}
+
+// Configure changes the level, format and rotation of the log
+func (l *Logger) Configure(opts Options) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.opts = opts
+	if l.file != nil {
+		l.file.setLimits(opts.MaxSize, opts.MaxFiles)
+	}
+}
+
+func (l *Logger) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields...) }
+func (l *Logger) Info(msg string, fields ...interface{})  { l.log(LevelInfo, msg, fields...) }
+func (l *Logger) Warn(msg string, fields ...interface{})  { l.log(LevelWarn, msg, fields...) }
+func (l *Logger) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields...) }
+
+// Log writes a record at level. fields are pairs of a key and its value:
+// Log(LevelInfo, "synced", "jql", jql, "issues", n)
+func (l *Logger) Log(level Level, msg string, fields ...interface{}) {
+	l.log(level, msg, fields...)
+}
+
+// log writes a record, noting where outside this package it was logged from
+func (l *Logger) log(level Level, msg string, fields ...interface{}) {
+	l.write(level, msg, caller(), fields)
+}
+
+func (l *Logger) write(level Level, msg, source string, fields []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.opts.Level {
+		return
+	}
+	r := record{time: time.Now(), level: level, msg: msg, source: source}
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		var value interface{} = "(missing)"
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		r.fields = append(r.fields, field{key, value})
+	}
+	l.out.Write(l.format(r))
+	if l.file == nil {
+		return
+	}
+	if err := l.file.takeRotateErr(); err != nil && LevelError >= l.opts.Level {
+		failed := record{time: time.Now(), level: LevelError, msg: "error rotating the log", fields: []field{{"err", err}}}
+		l.out.Write(l.format(failed))
+	}
+}
+
+func (l *Logger) format(r record) []byte {
+	if l.opts.Format == FormatJSON {
+		return r.json()
+	}
+	return r.logfmt()
+}
+
+// Printer is a *log.Logger that writes each line printed to it as a record at level,
+// for code that logs with Printf
+func (l *Logger) Printer(level Level) *log.Logger {
+	return log.New(printer{l, level}, "", 0)
+}
+
+type printer struct {
+	logger *Logger
+	level  Level
+}
+
+func (p printer) Write(line []byte) (int, error) {
+	p.logger.write(p.level, strings.TrimSuffix(string(line), "\n"), caller(), nil)
+	return len(line), nil
+}
+
+// caller is the file and line that logged, found by skipping the frames in this package and package log
+func caller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		if !strings.HasPrefix(fn, "log.") && !strings.HasPrefix(fn, "github.com/trevor-atlas/zilla/logger.") {
+			return fmt.Sprintf("%s:%d", path.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+type record struct {
+	time   time.Time
+	level  Level
+	msg    string
+	source string
+	fields []field
+}
+
+func (r record) logfmt() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time=%s level=%s msg=%s", r.time.Format(time.RFC3339Nano), r.level, logfmtValue(r.msg))
+	if r.source != "" {
+		fmt.Fprintf(&b, " source=%s", logfmtValue(r.source))
+	}
+	for _, f := range r.fields {
+		fmt.Fprintf(&b, " %s=%s", f.key, logfmtValue(formatValue(f.value)))
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+func (r record) json() []byte {
+	// encoding/json sorts map keys, so the record is written by hand to keep time, level and msg first
+	var b strings.Builder
+	b.WriteString("{")
+	writePair := func(key string, value interface{}) {
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(value)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(value))
+		}
+		if b.Len() > 1 {
+			b.WriteString(",")
+		}
+		b.Write(k)
+		b.WriteString(":")
+		b.Write(v)
+	}
+	writePair("time", r.time.Format(time.RFC3339Nano))
+	writePair("level", r.level.String())
+	writePair("msg", r.msg)
+	if r.source != "" {
+		writePair("source", r.source)
+	}
+	for _, f := range r.fields {
+		switch v := f.value.(type) {
+		case error:
+			writePair(f.key, v.Error())
+		case fmt.Stringer:
+			writePair(f.key, formatValue(v))
+		default:
+			writePair(f.key, v)
+		}
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// formatValue is how a field's value is written in logfmt
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case http.Header:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts := make([]string, 0, len(names))
+		for _, name := range names {
+			parts = append(parts, name+": "+strings.Join(v[name], ", "))
+		}
+		return strings.Join(parts, "; ")
+	}
+	return fmt.Sprint(value)
+}
+
+// logfmtValue quotes a value when it would otherwise be read as more than one
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\\\t\n\r") {
+		return strconv.Quote(s)
 	}
-	CommonLog = log.New(openLogfile, "info: ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLog = log.New(openLogfile, "err: ", log.Ldate|log.Ltime|log.Lshortfile)
-	return CommonLog, ErrorLog
+	return s
 }
@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotateRetry is how long after failing to rotate the log it's tried again
+const rotateRetry = time.Minute
+
+// rotatingFile is the log file. Once a write would take it past maxSize it's
+// renamed to log.txt.1, pushing the older logs along to log.txt.2 and so on,
+// and anything past maxFiles is deleted.
+type rotatingFile struct {
+	path string
+	// mu guards everything below, Logger holds its own lock but Configure can change the limits
+	mu       sync.Mutex
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	// rotateErr is why the log couldn't be rotated, until the logger takes it to
+	// write to the log. Rotating isn't tried again until retryAt.
+	rotateErr error
+	retryAt   time.Time
+}
+
+func openRotating(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the log for appending, readable only by the user as requests and errors can say a lot about them
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	// a log created by an older zilla was readable by everyone
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) setLimits(maxSize int64, maxFiles int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxSize = maxSize
+	r.maxFiles = maxFiles
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize && time.Now().After(r.retryAt) {
+		// the terminal belongs to the UI, so the failure is written to the log itself
+		if err := r.rotate(); err != nil {
+			r.rotateErr = err
+			r.retryAt = time.Now().Add(rotateRetry)
+		}
+		if r.file == nil {
+			return 0, os.ErrClosed
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// takeRotateErr returns why the log last couldn't be rotated, once
+func (r *rotatingFile) takeRotateErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.rotateErr
+	r.rotateErr = nil
+	return err
+}
+
+// rotate moves the current log aside and starts a new one. Another zilla
+// may have rotated it already, which is why a missing file isn't an error.
+// When the log can't be moved it's reopened where it is, so logging carries on
+// in the too-big file rather than stopping.
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+	err := r.shift()
+	if openErr := r.open(); openErr != nil && err == nil {
+		err = openErr
+	}
+	return err
+}
+
+// shift renames each log to the next number along, deleting the oldest
+func (r *rotatingFile) shift() error {
+	for i := r.maxFiles; i >= 1; i-- {
+		from := r.rotated(i - 1)
+		if err := os.Rename(from, r.rotated(i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if r.maxFiles < 1 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotated is the name of the nth newest rotated log, the 0th being the log itself
+func (r *rotatingFile) rotated(n int) string {
+	if n == 0 {
+		return r.path
+	}
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
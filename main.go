@@ -2,22 +2,35 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/trevor-atlas/zilla/cache"
 	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/secrets"
 	"github.com/trevor-atlas/zilla/util"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
-type item struct {
-	title, desc string
-}
+const (
+	defaultJQL = "assignee = currentUser() order by status asc"
+	// pageSize is how many issues are requested from the search endpoint at a time
+	pageSize = 50
+	// prefetchThreshold is how close to the end of the list the cursor gets before the next page is requested
+	prefetchThreshold = 5
+	// syncInterval is how often the current query is synced while the app is open
+	syncInterval = 5 * time.Minute
+)
 
 var docStyle = lipgloss.NewStyle()
 var style = lipgloss.NewStyle().
@@ -26,37 +39,131 @@ var style = lipgloss.NewStyle().
 	Border(lipgloss.NormalBorder(), false, false, false, true).
 	PaddingTop(2)
 
-func (i item) Title() string       { return i.title }
-func (i item) Description() string { return i.desc }
-func (i item) FilterValue() string { return i.title }
+// needsProfile reports whether the command can only run with a profile that
+// exists. setup is how a new profile gets filled in, and doctor and config show
+// are how to find out what's wrong when the profile picked isn't there.
+func needsProfile(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	switch args[0] {
+	case "setup", "doctor":
+		return false
+	case "config":
+		return len(args) < 2 || args[1] != "show"
+	}
+	return true
+}
 
 func main() {
-	app := util.New()
-	service := jira.NewService(app)
-	initialModel := createModel(app, service)
-
-	err := tea.NewProgram(initialModel, tea.WithAltScreen()).Start()
+	overrides, args, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
+		os.Exit(2)
+	}
+	app := util.New(overrides)
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	// doctor is for finding out what's wrong with the config, so it runs whatever state the config is in
+	if command != "doctor" {
+		if problem, fatal := util.Fatal(app.Problems); fatal {
+			fmt.Fprintln(os.Stderr, problem)
+			os.Exit(1)
+		}
+		if command != "" && command != "setup" {
+			for _, problem := range app.Problems {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", problem)
+			}
+		}
+	}
+	if err := app.CheckProfile(); err != nil && needsProfile(args) {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(2)
+	}
+	if len(args) == 0 && !util.ConfigExists() && !app.Overridden() {
+		done, err := runSetup(app)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !done {
+			return
+		}
+	}
+	service := jira.NewService(app)
+	if len(args) > 0 {
+		os.Exit(runCommand(app, service, args))
+	}
+	store, err := cache.Load(app.Config.Profile)
+	if err != nil {
+		app.Err.Printf("error loading the issue cache: %s", err)
+	}
+	model := createModel(app, service, store)
+
+	for {
+		model.stopped = make(chan struct{})
+		secrets.TerminalInUse(true)
+		final, err := tea.NewProgram(model, tea.WithAltScreen()).StartReturningModel()
+		secrets.TerminalInUse(false)
+		close(model.stopped)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		model = final.(Model)
+		// the program quits to give the terminal to $EDITOR, then picks up where it left off
+		if model.editing == nil {
+			return
+		}
+		model = editComments(model)
 	}
 }
 
-func createModel(app *util.Zilla, service jira.ClientService) Model {
+func createModel(app *util.Zilla, service jira.ClientService, store *cache.Cache) Model {
+	retries := make(chan util.Retry, 16)
+	background := util.WithRetryObserver(context.Background(), func(r util.Retry) {
+		// drop the news rather than hold up the request when the UI is behind
+		select {
+		case retries <- r:
+		default:
+		}
+	})
+	return newModel(app, service, store, background, retries)
+}
+
+// newModel is a fresh UI for service and store, it's used again when switching profiles
+func newModel(app *util.Zilla, service jira.ClientService, store *cache.Cache, background context.Context, retries chan util.Retry) Model {
 	t := textinput.New()
+	t.Placeholder = defaultJQL
 	t.Focus()
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	var items []list.Item
+	offline := cache.NewOfflineService(service, store)
 	model := Model{
 		app:        *app,
 		textInput:  t,
 		spinner:    s,
 		viewport:   viewport.New(0, 0),
 		typing:     true,
-		jiraClient: service,
-		list:       list.New(items, list.NewDefaultDelegate(), 0, 0),
+		jiraClient: offline,
+		cache:      store,
+		outbox:     offline,
+		background: background,
+		retries:    retries,
+		list:       list.New(items, newIssueDelegate(), 0, 0),
+		details:    make(map[string]jira.JiraIssue),
+	}
+	model.list.Filter = indexFilter(store, app.Err)
+	// pick up where the last session left off, refreshing in the background
+	if jql, _, ok := store.Last(); ok {
+		model.textInput.SetValue(jql)
+		model, model.pending = model.runQuery(jql)
 	}
 	return model
 }
@@ -66,34 +173,328 @@ type Model struct {
 	textInput  textinput.Model
 	spinner    spinner.Model
 	jiraClient jira.ClientService
+	cache      *cache.Cache
+	// outbox is jiraClient, it queues changes while offline and sends them later
+	outbox *cache.OfflineService
+	// background is the parent of every request's context, it reports retries to retries
+	background context.Context
+	retries    chan util.Retry
 
 	viewport viewport.Model
 	ready    bool
-	typing   bool
-	loading  bool
-	err      error
-	issues   jira.JiraIssues
-	list     list.Model
+	// width and height are the size of the terminal, kept for the new UI when switching profiles
+	width        int
+	height       int
+	typing       bool
+	loading      bool
+	fetchingMore bool
+	err          error
+	// stale is set while the list shows cached results that are being refreshed
+	stale bool
+	// syncing is set while the current query is being synced, paging waits for it to finish
+	syncing bool
+	// grepping is set while the prompt is for a search of the local database rather than JQL,
+	// grep is the search whose results are being shown
+	grepping bool
+	grep     string
+	// offline is set when the last request couldn't reach jira
+	offline bool
+	query   string
+	// ctx is cancelled when the query is replaced or abandoned, stopping its requests
+	ctx    context.Context
+	cancel context.CancelFunc
+	search *jira.SearchIterator
+	issues jira.JiraIssues
+	list   list.Model
+	// filtering is set while the list's filter is open, the list then holds
+	// every synced issue for it to search rather than the results being shown
+	filtering bool
+	// details holds fully loaded issues (with comments) by key
+	details   map[string]jira.JiraIssue
+	detailKey string
+
+	transitions *transitionPicker
+	comments    *commentPicker
+	create      *createForm
+	edit        *editForm
+	editing     *commentEdit
+	profiles    *profilePicker
+	// pending is run when the program starts, or starts back up after editing
+	pending tea.Cmd
+	// stopped is closed when the program running the model exits, so that the
+	// commands waiting on retries and the sync timer stop with it rather than
+	// hang on into the program started after editing
+	stopped chan struct{}
 }
 
 type GotIssues struct {
 	Err    error
+	JQL    string
+	Issues jira.JiraIssues
+}
+
+// GotNextPage is sent when a subsequent page of the current search arrives
+type GotNextPage struct {
+	Err    error
+	JQL    string
 	Issues jira.JiraIssues
 }
 
-func (m Model) fetchIssues() tea.Cmd {
+// Retrying is sent when a request is rate limited or jira is unavailable and the request will be tried again
+type Retrying struct {
+	util.Retry
+}
+
+// Status describes the retry for the status line
+func (r Retrying) Status() string {
+	if r.StatusCode == http.StatusTooManyRequests {
+		return fmt.Sprintf("Rate limited by jira, retrying in %s", r.Wait.Round(time.Second))
+	}
+	return fmt.Sprintf("Jira responded %d, retrying in %s", r.StatusCode, r.Wait.Round(time.Second))
+}
+
+func (m Model) waitForRetry() tea.Cmd {
+	stopped := m.stopped
+	return func() tea.Msg {
+		select {
+		case r := <-m.retries:
+			return Retrying{r}
+		case <-stopped:
+			// leave the news for the program that takes over after editing
+			return nil
+		}
+	}
+}
+
+// SyncTick is sent every syncInterval to pull in issues changed since the last sync
+type SyncTick struct{}
+
+// syncIssues brings the cached results for a query up to date, fetching only
+// the issues that changed since the last time it was synced
+func (m Model) syncIssues(jql string) tea.Cmd {
+	ctx := m.ctx
+	return func() tea.Msg {
+		entry, err := m.cache.Sync(ctx, m.jiraClient, jql, pageSize)
+		if err != nil {
+			return GotIssues{Err: err, JQL: jql}
+		}
+
+		return GotIssues{Issues: entry.Issues, JQL: jql}
+	}
+}
+
+func (m Model) syncTick() tea.Cmd {
+	stopped := m.stopped
+	return func() tea.Msg {
+		t := time.NewTimer(syncInterval)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return SyncTick{}
+		case <-stopped:
+			// the program that takes over after editing starts its own
+			return nil
+		}
+	}
+}
+
+func (m Model) fetchNextPage(jql string, search *jira.SearchIterator) tea.Cmd {
+	ctx := m.ctx
 	return func() tea.Msg {
-		issues, err := m.jiraClient.GetIssues(context.Background())
+		issues, err := search.Next(ctx)
 		if err != nil {
-			return GotIssues{Err: err}
+			return GotNextPage{Err: err, JQL: jql}
 		}
 
-		return GotIssues{Issues: *issues}
+		return GotNextPage{Issues: *issues, JQL: jql}
+	}
+}
+
+// runQuery starts a search. Cached results for the query are shown
+// straight away, marked as stale until the fresh results arrive.
+func (m Model) runQuery(jql string) (Model, tea.Cmd) {
+	m.typing = false
+	m.err = nil
+	m.fetchingMore = false
+	m.syncing = true
+	m.grep = ""
+	m.query = jql
+	m.closeFilter()
+	m.stopQuery()
+	m.ctx, m.cancel = context.WithCancel(m.background)
+	m.search = jira.NewSearchIterator(m.jiraClient, jql, pageSize)
+	fetch := m.syncIssues(jql)
+
+	if entry, ok := m.cache.Get(jql); ok {
+		m.stale = true
+		m.loading = false
+		return m, tea.Batch(m.showIssues(entry.Issues), fetch)
 	}
+	m.stale = false
+	m.loading = true
+	return m, tea.Batch(spinner.Tick, fetch)
+}
+
+// runGrep shows the synced issues matching a full text search of the local database
+func (m Model) runGrep(text string) (Model, tea.Cmd) {
+	m.typing = false
+	m.grepping = false
+	m.err = nil
+	issues, err := m.cache.Search(text)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	// leaving the query behind stops results for it, paging and syncing from replacing the matches
+	m.stopQuery()
+	m.query = ""
+	m.search = nil
+	m.stale = false
+	m.syncing = false
+	m.fetchingMore = false
+	m.grep = text
+	m.closeFilter()
+	return m, m.showIssues(jira.JiraIssues{Total: len(issues), Issues: issues})
+}
+
+// stopQuery cancels whatever requests the current query still has in flight
+func (m *Model) stopQuery() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// showIssues replaces the contents of the list, keeping the selected issue selected if it's still there
+func (m *Model) showIssues(issues jira.JiraIssues) tea.Cmd {
+	selected := m.detailKey
+	m.issues = issues
+	m.list.Title = m.listTitle()
+	if m.filtering {
+		// the filter is searching every synced issue, these are shown once it's cleared
+		return nil
+	}
+	cmd := m.list.SetItems(issueItems(issues.Issues))
+	m.list.ResetSelected()
+	for i, issue := range issues.Issues {
+		if issue.Key == selected {
+			m.list.Select(i)
+			break
+		}
+	}
+	m.detailKey = ""
+	return tea.Batch(cmd, m.syncDetail())
+}
+
+// cachePage adds a further page of the current query's results to the cache
+func (m Model) cachePage(page jira.JiraIssues) tea.Cmd {
+	jql := m.query
+	return func() tea.Msg {
+		if err := m.cache.Append(jql, page); err != nil {
+			m.app.Err.Printf("error writing the issue cache: %s", err)
+		}
+		return nil
+	}
+}
+
+// storeIssue saves a fully loaded issue to the local database so its comments can be searched
+func (m Model) storeIssue(issue jira.JiraIssue) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.cache.PutIssue(issue); err != nil {
+			m.app.Err.Printf("error writing the issue cache: %s", err)
+		}
+		return nil
+	}
+}
+
+// nearBottom reports whether the list cursor is close enough to the last
+// loaded issue that the next page should be requested
+func (m Model) nearBottom() bool {
+	return m.list.Index() >= len(m.list.Items())-prefetchThreshold
+}
+
+// selectedIssue returns the issue under the list cursor, if there is one
+func (m Model) selectedIssue() (jira.JiraIssue, bool) {
+	i, ok := m.list.SelectedItem().(item)
+	if !ok {
+		return jira.JiraIssue{}, false
+	}
+	return i.issue, true
+}
+
+// replaceIssue swaps in a newer copy of an issue that is already in the list
+func (m *Model) replaceIssue(issue jira.JiraIssue) tea.Cmd {
+	for i, existing := range m.issues.Issues {
+		if existing.Key == issue.Key {
+			m.issues.Issues[i] = issue
+			break
+		}
+	}
+	for i, listed := range m.list.Items() {
+		if listed.(item).issue.Key == issue.Key {
+			return m.list.SetItem(i, item{issue: issue})
+		}
+	}
+	return nil
+}
+
+// openFilter fills the list with every synced issue for the filter to search,
+// the results being shown first so the list doesn't change until something's typed
+func (m *Model) openFilter() tea.Cmd {
+	synced, err := m.cache.Issues()
+	if err != nil {
+		m.app.Err.Printf("error reading the issue cache: %s", err)
+		return nil
+	}
+	shown := make(map[string]bool, len(m.issues.Issues))
+	issues := append([]jira.JiraIssue{}, m.issues.Issues...)
+	for _, issue := range issues {
+		shown[issue.Key] = true
+	}
+	for _, issue := range synced {
+		if !shown[issue.Key] {
+			issues = append(issues, issue)
+		}
+	}
+	m.filtering = true
+	return m.list.SetItems(issueItems(issues))
+}
+
+// closeFilter puts the results back in the list once the filter is cleared
+func (m *Model) closeFilter() tea.Cmd {
+	if !m.filtering {
+		return nil
+	}
+	m.filtering = false
+	m.list.ResetFilter()
+	cmd := m.list.SetItems(issueItems(m.issues.Issues))
+	m.list.ResetSelected()
+	return cmd
+}
+
+// browsing reports whether the issue list has focus and isn't capturing keys for its filter
+func (m Model) browsing() bool {
+	return m.ready && !m.typing && !m.loading && m.err == nil && !m.list.SettingFilter()
+}
+
+func (m Model) listTitle() string {
+	if m.grep != "" {
+		return fmt.Sprintf("Matches for %q (%d)", m.grep, len(m.issues.Issues))
+	}
+	title := fmt.Sprintf("Issues (%d of %d)", len(m.issues.Issues), m.issues.Total)
+	if m.stale {
+		title += " · stale"
+	}
+	if m.offline {
+		title += " · offline"
+	}
+	if profile := m.app.Config.Profile; profile != "" {
+		title = profile + " · " + title
+	}
+	return title
 }
 
 func (m Model) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, m.syncTick(), m.waitForRetry(), m.pending)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -103,90 +504,293 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	)
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.transitions != nil && msg.String() != "ctrl+c" {
+			return m.updateTransitionPicker(msg)
+		}
+		if m.comments != nil && msg.String() != "ctrl+c" {
+			return m.updateCommentPicker(msg)
+		}
+		if m.create != nil && msg.String() != "ctrl+c" {
+			return m.updateCreateForm(msg)
+		}
+		if m.edit != nil && msg.String() != "ctrl+c" {
+			return m.updateEditForm(msg)
+		}
+		if m.profiles != nil && msg.String() != "ctrl+c" {
+			return m.updateProfilePicker(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			return m, tea.Quit
 		case "enter":
+			if m.typing && m.grepping {
+				return m.runGrep(strings.TrimSpace(m.textInput.Value()))
+			}
 			if m.typing {
 				query := strings.TrimSpace(m.textInput.Value())
-				if query != "" {
-					m.typing = false
-					m.loading = true
-					return m, tea.Batch(
-						spinner.Tick,
-						m.fetchIssues(),
-					)
+				if query == "" {
+					query = defaultJQL
 				}
+				return m.runQuery(query)
+			}
+
+		case "t":
+			if m.browsing() {
+				return m.openTransitionPicker()
+			}
+
+		case "c":
+			if issue, ok := m.selectedIssue(); ok && m.browsing() {
+				return m.startEditing(commentEdit{key: issue.Key})
+			}
+
+		case "r":
+			if m.browsing() {
+				return m.openCommentPicker(replyToComment)
+			}
+
+		case "e":
+			if m.browsing() {
+				return m.openCommentPicker(editComment)
+			}
+
+		case "x":
+			if m.browsing() {
+				return m.openCommentPicker(deleteComment)
+			}
+
+		case "n":
+			if m.browsing() {
+				return m.openCreateForm()
+			}
+
+		case "E":
+			if m.browsing() {
+				return m.openEditForm()
+			}
+
+		case "p":
+			if m.browsing() {
+				return m.openProfilePicker()
+			}
+
+		case "s":
+			if m.browsing() {
+				m.typing = true
+				m.grepping = true
+				m.textInput.SetValue(m.grep)
+				m.textInput.CursorEnd()
+				return m, nil
 			}
 
 		case "esc":
-			if !m.typing && !m.loading {
+			if m.loading {
+				// give up on the query and go back to editing it
+				m.stopQuery()
+				m.query = ""
+				m.loading = false
+				m.syncing = false
 				m.typing = true
+				return m, nil
+			}
+			// with a filter applied esc clears it, which the list does below
+			if !m.typing && m.list.FilterState() == list.Unfiltered {
+				m.typing = true
+				m.grepping = false
 				m.err = nil
+				if m.query != "" {
+					m.textInput.SetValue(m.query)
+					m.textInput.CursorEnd()
+				}
 				return m, nil
 			}
 		}
 
 	case GotIssues:
+		if msg.JQL != m.query {
+			return m, nil
+		}
 		m.loading = false
-		m.typing = false
+		m.syncing = false
 
 		if err := msg.Err; err != nil {
+			m.offline = util.IsNetworkError(err)
+			m.list.Title = m.listTitle()
+			if m.offline && len(m.issues.Issues) > 0 {
+				return m, m.list.NewStatusMessage("Offline, showing the issues synced last time")
+			}
+			if len(m.issues.Issues) > 0 {
+				// keep showing what we have
+				return m, m.list.NewStatusMessage(fmt.Sprintf("Could not refresh issues: %s", describeError(err)))
+			}
 			m.err = err
 			return m, nil
 		}
 
-		var issues jira.JiraIssues
-		for i, _ := range m.issues.Issues {
-			mockissue := jira.JiraIssue{
-				ID:   fmt.Sprintf("%s", i),
-				Self: fmt.Sprintf("%s", i),
-				Key:  fmt.Sprintf("ABC-%s", i),
-				Fields: jira.IssueFields{
-					Summary:     "an issue summary",
-					Created:     nil,
-					Updated:     nil,
-					Description: "Bacon ipsum dolor amet pig turkey bresaola, jowl fatback venison t-bone andouille. Boudin pork belly chicken meatball, short ribs shankle pork t-bone cow biltong doner. Brisket short ribs ribeye frankfurter pork loin buffalo shank picanha tenderloin turducken boudin pig. Picanha cupim ham ham hock burgdoggen pancetta chicken spare ribs salami landjaeger sausage brisket bacon kevin tenderloin.",
-					Reporter: jira.IssueUser{
-						Active:       false,
-						TimeZone:     "",
-						DisplayName:  "",
-						Name:         "",
-						EmailAddress: "",
-						AvatarUrls:   nil,
-						AccountId:    "",
-						Key:          "",
-						Self:         "",
-					},
-					Assignee: jira.IssueUser{
-						Active:       false,
-						TimeZone:     "",
-						DisplayName:  "",
-						Name:         "",
-						EmailAddress: "",
-						AvatarUrls:   nil,
-						AccountId:    "",
-						Key:          "",
-						Self:         "",
-					},
-					Comment:   jira.IssueComments{},
-					Priority:  jira.IssuePriority{},
-					IssueType: jira.IssueType{},
-					Status:    jira.IssueStatus{},
-					Project:   jira.IssueProject{},
-				},
+		m.stale = false
+		m.offline = false
+		m.search.SkipTo(len(msg.Issues.Issues), msg.Issues.Total)
+		// jira is reachable, so send anything queued while it wasn't
+		return m, tea.Batch(m.showIssues(msg.Issues), m.flushOutbox())
+
+	case OutboxFlushed:
+		return m.updateOutboxFlushed(msg)
+
+	case Retrying:
+		return m, tea.Batch(m.list.NewStatusMessage(msg.Status()), m.waitForRetry())
+
+	case SyncTick:
+		if m.query != "" && !m.syncing && !m.fetchingMore && !m.loading {
+			m.syncing = true
+			return m, tea.Batch(m.syncIssues(m.query), m.syncTick())
+		}
+		return m, m.syncTick()
+
+	case GotNextPage:
+		if msg.JQL != m.query {
+			return m, nil
+		}
+		m.fetchingMore = false
+
+		if err := msg.Err; err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not fetch more issues: %s", describeError(err)))
+		}
+
+		// issues that moved up the results since the last page can show up twice
+		loaded := make(map[string]bool, len(m.issues.Issues))
+		for _, issue := range m.issues.Issues {
+			loaded[issue.Key] = true
+		}
+		for _, issue := range msg.Issues.Issues {
+			if !loaded[issue.Key] {
+				m.issues.Issues = append(m.issues.Issues, issue)
 			}
-			issues.Issues = append(issues.Issues, mockissue)
+		}
+		m.issues.Total = msg.Issues.Total
+		m.list.Title = m.listTitle()
+		if m.filtering {
+			return m, m.cachePage(msg.Issues)
+		}
+		return m, tea.Batch(m.list.SetItems(issueItems(m.issues.Issues)), m.cachePage(msg.Issues))
+
+	case GotIssueDetail:
+		if err := msg.Err; err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not load %s: %s", msg.Issue.Key, describeError(err)))
+		}
+		m.details[msg.Issue.Key] = msg.Issue
+		cmd = m.replaceIssue(msg.Issue)
+		if msg.Issue.Key == m.detailKey {
+			m.renderDetail()
+		}
+		return m, tea.Batch(cmd, m.storeIssue(msg.Issue))
+
+	case GotTransitions:
+		if m.transitions == nil || m.transitions.key != msg.Key {
+			return m, nil
+		}
+		if err := msg.Err; err != nil {
+			m.transitions = nil
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not load transitions for %s: %s", msg.Key, describeError(err)))
+		}
+		m.transitions.loading = false
+		m.transitions.transitions = msg.Transitions
+		return m, nil
+
+	case TransitionDone:
+		m.transitions = nil
+		if cmd, ok := m.queued(msg.Err, fmt.Sprintf("moving %s to %s", msg.Key, msg.Name)); ok {
+			return m, cmd
+		}
+		if err := msg.Err; err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not transition %s: %s", msg.Key, describeError(err)))
+		}
+		delete(m.details, msg.Key)
+		return m, tea.Batch(
+			m.list.NewStatusMessage(fmt.Sprintf("%s moved to %s", msg.Key, msg.Name)),
+			m.fetchIssueDetail(msg.Key),
+		)
+
+	case CommentSaved:
+		if cmd, ok := m.queued(msg.Err, fmt.Sprintf("the comment on %s", msg.Key)); ok {
+			return m, cmd
+		}
+		if err := msg.Err; err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not save comment on %s: %s", msg.Key, describeError(err)))
+		}
+		status := fmt.Sprintf("Comment saved on %s", msg.Key)
+		if msg.Deleted {
+			status = fmt.Sprintf("Comment deleted from %s", msg.Key)
+		}
+		delete(m.details, msg.Key)
+		return m, tea.Batch(m.list.NewStatusMessage(status), m.fetchIssueDetail(msg.Key))
+
+	case GotCreateMeta:
+		if m.create == nil {
+			return m, nil
+		}
+		if err := msg.Err; err != nil {
+			m.create.step = chooseProject
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not load project: %s", describeError(err)))
+		}
+		m.create.meta = msg.Project
+		m.create.step = chooseIssueType
+		return m, nil
 
-			m.list.InsertItem(i, item{title: mockissue.Key, desc: mockissue.Fields.Summary})
+	case IssueCreated:
+		if err := msg.Err; err != nil {
+			if m.create != nil {
+				m.create.step = fillFields
+			}
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not create issue: %s", describeError(err)))
+		}
+		m.create = nil
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Created %s", msg.Key))
 
-			m.issues = issues //msg.Issues
-			m.list.Title = "Issues"
+	case GotEditMeta:
+		if m.edit == nil || m.edit.key != msg.Key {
+			return m, nil
 		}
+		if err := msg.Err; err != nil {
+			m.edit = nil
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not load fields for %s: %s", msg.Key, describeError(err)))
+		}
+		m.edit.loading = false
+		m.edit.fields = editableFields(msg.Fields)
+		return m, nil
 
+	case IssueUpdated:
+		if m.edit != nil {
+			m.edit.loading = false
+		}
+		if cmd, ok := m.queued(msg.Err, fmt.Sprintf("the change to %s on %s", msg.Field, msg.Key)); ok {
+			m.edit = nil
+			return m, cmd
+		}
+		if err := msg.Err; err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not update %s: %s", msg.Key, describeError(err)))
+		}
+		m.edit = nil
+		delete(m.details, msg.Key)
+		return m, tea.Batch(
+			m.list.NewStatusMessage(fmt.Sprintf("Updated %s on %s", msg.Field, msg.Key)),
+			m.fetchIssueDetail(msg.Key),
+		)
+
+	case GotUsers:
+		f := m.activeField()
+		if f == nil || f.kind != userField || strings.TrimSpace(f.text.Value()) != msg.Query {
+			return m, nil
+		}
+		if err := msg.Err; err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("Could not search for users: %s", describeError(err)))
+		}
+		f.users = msg.Users
+		f.searched = msg.Query
+		f.cursor = 0
 		return m, nil
 
 	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
 		m.list.SetSize(msg.Width/3-1, msg.Height)
 		contentWidth := (msg.Width / 3) * 2
 		style.Width(contentWidth).Height(msg.Height)
@@ -200,7 +804,6 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport = viewport.New(contentWidth, msg.Height)
 			//m.viewport.YPosition = headerHeight
 			//m.viewport.HighPerformanceRendering = true
-			m.viewport.SetContent(m.issues.Issues[m.list.Cursor()].Fields.Description)
 			m.ready = true
 
 			// This is only necessary for high performance rendering, which in
@@ -213,6 +816,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport.Width = contentWidth
 			m.viewport.Height = msg.Height
 		}
+		m.renderDetail()
 		return m, nil
 	}
 
@@ -228,8 +832,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	if msg, ok := msg.(tea.KeyMsg); ok && !m.filtering && m.browsing() && key.Matches(msg, m.list.KeyMap.Filter) {
+		cmds = append(cmds, m.openFilter())
+	}
 	m.list, cmd = m.list.Update(msg)
 	cmds = append(cmds, cmd)
+	if m.filtering && m.list.FilterState() == list.Unfiltered {
+		cmds = append(cmds, m.closeFilter())
+	}
+	cmds = append(cmds, m.syncDetail())
+
+	if !m.fetchingMore && !m.syncing && !m.filtering && m.search != nil && m.search.HasNext() && m.nearBottom() {
+		m.fetchingMore = true
+		cmds = append(cmds, m.fetchNextPage(m.query, m.search))
+	}
 
 	m.viewport, cmd = m.viewport.Update(msg)
 	cmds = append(cmds, cmd)
@@ -241,17 +857,53 @@ func (m Model) View() string {
 	if !m.ready {
 		return fmt.Sprintf("\ninitializing %s", m.spinner.View())
 	}
+	if m.typing && m.grepping {
+		return fmt.Sprintf("Search synced issues:\n%s", m.textInput.View())
+	}
 	if m.typing {
-		return fmt.Sprintf("Enter location:\n%s", m.textInput.View())
+		prompt := "Enter a JQL query:"
+		if profile := m.app.Config.Profile; profile != "" {
+			prompt = fmt.Sprintf("Enter a JQL query for %s:", profile)
+		}
+		view := fmt.Sprintf("%s\n%s", prompt, m.textInput.View())
+		switch n := len(m.app.Problems); {
+		case n == 1:
+			view += "\n\nThere is a problem with the config, zilla doctor shows it"
+		case n > 1:
+			view += fmt.Sprintf("\n\nThere are %d problems with the config, zilla doctor shows them", n)
+		}
+		return view
 	}
 
 	if m.loading {
-		return fmt.Sprintf("%s fetching issues... please wait.", m.spinner.View())
+		return fmt.Sprintf("%s fetching issues... esc to cancel", m.spinner.View())
 	}
 
 	if err := m.err; err != nil {
-		return fmt.Sprintf("Could not fetch issues: %v", err)
+		hint := errorHint(err)
+		if errors.Is(err, jira.ErrInvalid) {
+			hint = "check the JQL, press esc to edit the query"
+		}
+		return fmt.Sprintf("Could not fetch issues: %v\n\n%s", err, hint)
 	}
 
-	return lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), m.viewport.View())
+	return lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), m.paneView())
+}
+
+// paneView renders the right hand pane, which shows whichever picker
+// or form is open in place of the issue detail
+func (m Model) paneView() string {
+	switch {
+	case m.transitions != nil:
+		return m.transitions.View()
+	case m.comments != nil:
+		return m.comments.View()
+	case m.create != nil:
+		return m.create.View()
+	case m.edit != nil:
+		return m.edit.View()
+	case m.profiles != nil:
+		return m.profiles.View()
+	}
+	return m.viewport.View()
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/cache"
+	"github.com/trevor-atlas/zilla/util"
+)
+
+// OutboxFlushed is sent once the changes queued while offline have been sent, as far as possible
+type OutboxFlushed struct {
+	Err       error
+	Sent      int
+	Conflicts []cache.QueuedChange
+}
+
+func (m Model) flushOutbox() tea.Cmd {
+	return func() tea.Msg {
+		sent, conflicts, err := m.outbox.Flush(m.background)
+		return OutboxFlushed{Err: err, Sent: sent, Conflicts: conflicts}
+	}
+}
+
+// updateOutboxFlushed reports how sending the outbox went
+func (m Model) updateOutboxFlushed(msg OutboxFlushed) (Model, tea.Cmd) {
+	if util.IsNetworkError(msg.Err) {
+		m.offline = true
+		m.list.Title = m.listTitle()
+	}
+	switch {
+	case len(msg.Conflicts) == 1:
+		c := msg.Conflicts[0]
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Could not send %s: %s, see zilla outbox", c.Describe(), c.Conflict))
+	case len(msg.Conflicts) > 1:
+		return m, m.list.NewStatusMessage(fmt.Sprintf("%d queued changes conflict with changes on the server, see zilla outbox", len(msg.Conflicts)))
+	case msg.Err != nil && !util.IsNetworkError(msg.Err):
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Could not send queued changes: %s", describeError(msg.Err)))
+	case msg.Sent > 0:
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Sent %d changes made while offline", msg.Sent))
+	}
+	return m, nil
+}
+
+// queued reports whether err means a change was saved to the outbox rather than sent,
+// returning the status message to show for it
+func (m *Model) queued(err error, what string) (tea.Cmd, bool) {
+	if !errors.Is(err, cache.ErrQueued) {
+		return nil, false
+	}
+	m.offline = true
+	m.list.Title = m.listTitle()
+	return m.list.NewStatusMessage(fmt.Sprintf("Offline, %s will be sent once reconnected", what)), true
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/cache"
+	"github.com/trevor-atlas/zilla/jira"
+	"strings"
+)
+
+// profilePicker is the overlay for switching to another jira profile
+type profilePicker struct {
+	names  []string
+	cursor int
+}
+
+func (m Model) openProfilePicker() (Model, tea.Cmd) {
+	names := m.app.FileConfig.ProfileNames()
+	if len(names) == 0 {
+		return m, m.list.NewStatusMessage("There are no profiles in the config to switch between")
+	}
+	p := &profilePicker{names: names}
+	for i, name := range names {
+		if name == m.app.Config.Profile {
+			p.cursor = i
+		}
+	}
+	m.profiles = p
+	return m, nil
+}
+
+func (m Model) updateProfilePicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.profiles
+	switch msg.String() {
+	case "esc":
+		m.profiles = nil
+	case "up", "k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "j":
+		if p.cursor < len(p.names)-1 {
+			p.cursor++
+		}
+	case "enter":
+		m.profiles = nil
+		if name := p.names[p.cursor]; name != m.app.Config.Profile {
+			return m.switchProfile(name)
+		}
+	}
+	return m, nil
+}
+
+// switchProfile starts the UI over for another profile, with its own jira
+// service and its own cache, picking up the last query run with that profile
+func (m Model) switchProfile(name string) (Model, tea.Cmd) {
+	app := m.app
+	if err := app.UseProfile(name); err != nil {
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Could not switch profile: %v", err))
+	}
+	store, err := cache.Load(name)
+	if err != nil {
+		app.Err.Printf("error loading the issue cache for profile %s: %s", name, err)
+	}
+	app.Info.Printf("switched to profile %s", name)
+
+	m.stopQuery()
+	next := newModel(&app, jira.NewService(&app), store, m.background, m.retries)
+	// the running program carries on with the new UI, and so do its retry listener and sync timer
+	next.stopped = m.stopped
+	if m.ready {
+		resized, _ := next.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		next = resized.(Model)
+	}
+	cmds := []tea.Cmd{textinput.Blink, next.pending, next.list.NewStatusMessage(fmt.Sprintf("Switched to %s", name))}
+	next.pending = nil
+	return next, tea.Batch(cmds...)
+}
+
+func (p profilePicker) View() string {
+	var b strings.Builder
+	b.WriteString(overlayTitleStyle.Render("Switch profile"))
+	b.WriteString("\n")
+	for i, name := range p.names {
+		b.WriteString(cursorLine(name, i == p.cursor))
+		b.WriteString("\n")
+	}
+	b.WriteString(overlayHintStyle.Render("enter: switch • esc: cancel"))
+	return overlayStyle.Render(b.String())
+}
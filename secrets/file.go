@@ -0,0 +1,226 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"github.com/trevor-atlas/zilla/constants"
+	"github.com/trevor-atlas/zilla/dirs"
+	"golang.org/x/term"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+)
+
+// PassphraseEnv is read for the passphrase of the secrets file instead of prompting for it
+const PassphraseEnv = "ZILLA_PASSPHRASE"
+
+// terminalInUse is set while the UI is reading the terminal, the passphrase can't be asked for then
+var terminalInUse int32
+
+// TerminalInUse says whether the UI is reading the terminal. While it is, a
+// passphrase that isn't in ZILLA_PASSPHRASE is an error rather than a prompt,
+// which would fight the UI for the keyboard.
+func TerminalInUse(inUse bool) {
+	var v int32
+	if inUse {
+		v = 1
+	}
+	atomic.StoreInt32(&terminalInUse, v)
+}
+
+// FilePath is where the encrypted secrets file is kept, $XDG_CONFIG_HOME/zilla/secrets.age
+func FilePath() (string, error) {
+	return dirs.ConfigPath(constants.SECRETS_FILENAME)
+}
+
+// File keeps secrets in a json object encrypted with age, using a passphrase
+// from ZILLA_PASSPHRASE or asked for on the terminal. The passphrase is asked
+// for at most once, when the file is first read or created.
+type File struct {
+	path string
+	// mu guards everything below, the oauth token can be refreshed from several goroutines
+	mu         sync.Mutex
+	loaded     bool
+	passphrase string
+	secrets    map[string]string
+}
+
+// NewFile returns a store backed by the encrypted file at path, which is created when the first secret is set
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Backend() string { return BackendFile }
+
+func (f *File) Get(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return "", err
+	}
+	value, ok := f.secrets[name]
+	if !ok {
+		return "", notFound(name)
+	}
+	return value, nil
+}
+
+func (f *File) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return err
+	}
+	f.secrets[name] = value
+	return f.save()
+}
+
+func (f *File) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return err
+	}
+	if _, ok := f.secrets[name]; !ok {
+		return nil
+	}
+	delete(f.secrets, name)
+	return f.save()
+}
+
+// load decrypts the file the first time it's needed, a file that doesn't exist yet holds no secrets
+func (f *File) load() error {
+	if f.loaded {
+		return nil
+	}
+	contents, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		f.secrets = map[string]string{}
+		f.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := askPassphrase(f.path, false)
+	if err != nil {
+		return err
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return err
+	}
+	r, err := age.Decrypt(bytes.NewReader(contents), identity)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return fmt.Errorf("wrong passphrase for %s", f.path)
+	}
+	if err != nil {
+		return fmt.Errorf("error decrypting %s: %w", f.path, err)
+	}
+	plain, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error decrypting %s: %w", f.path, err)
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("error parsing %s: %s", f.path, err)
+	}
+	f.secrets = secrets
+	f.passphrase = passphrase
+	f.loaded = true
+	return nil
+}
+
+func (f *File) save() error {
+	if f.passphrase == "" {
+		// a new file, have the passphrase typed twice so a typo doesn't lock the secrets away
+		passphrase, err := askPassphrase(f.path, true)
+		if err != nil {
+			return err
+		}
+		f.passphrase = passphrase
+	}
+	recipient, err := age.NewScryptRecipient(f.passphrase)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(f.secrets)
+	if err != nil {
+		return err
+	}
+	var encrypted bytes.Buffer
+	w, err := age.Encrypt(&encrypted, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := dirs.Ensure(path.Dir(f.path)); err != nil {
+		return err
+	}
+	// write to a temp file and move it into place so a crash mid-write can't lose every secret
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, encrypted.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// askPassphrase returns the passphrase from ZILLA_PASSPHRASE, or asks for it on
+// the terminal unless the UI is using it. When confirm is set it is asked for
+// twice and has to match.
+func askPassphrase(file string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if atomic.LoadInt32(&terminalInUse) == 1 {
+		if confirm {
+			return "", fmt.Errorf("%s needs a passphrase, run zilla secrets migrate or zilla setup to create it, or set %s", file, PassphraseEnv)
+		}
+		return "", fmt.Errorf("the passphrase for %s can't be asked for while zilla is running, set %s", file, PassphraseEnv)
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("the passphrase for %s is needed, set %s", file, PassphraseEnv)
+	}
+	prompt := fmt.Sprintf("passphrase for %s: ", file)
+	if confirm {
+		prompt = fmt.Sprintf("new passphrase for %s: ", file)
+	}
+	passphrase, err := readPassword(fd, prompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase can't be empty")
+	}
+	if confirm {
+		again, err := readPassword(fd, "again: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("the passphrases don't match")
+		}
+	}
+	return passphrase, nil
+}
+
+func readPassword(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+	b, err := term.ReadPassword(fd)
+	return string(b), err
+}
@@ -0,0 +1,40 @@
+package secrets
+
+import (
+	"errors"
+	"github.com/zalando/go-keyring"
+)
+
+// service is what zilla's entries in the keyring are filed under
+const service = "zilla"
+
+// Keyring keeps secrets in the OS keyring
+type Keyring struct{}
+
+// KeyringAvailable reports whether there is a keyring to keep secrets in, a
+// headless linux box often has no Secret Service running
+func KeyringAvailable() bool {
+	_, err := keyring.Get(service, "zilla-probe")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+func (Keyring) Backend() string { return BackendKeyring }
+
+func (Keyring) Get(name string) (string, error) {
+	value, err := keyring.Get(service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", notFound(name)
+	}
+	return value, err
+}
+
+func (Keyring) Set(name, value string) error {
+	return keyring.Set(service, name, value)
+}
+
+func (Keyring) Delete(name string) error {
+	if err := keyring.Delete(service, name); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package secrets keeps credentials out of the config file. The config refers
+// to a secret by name, as secret:<name>, and the value lives in the OS keyring
+// or, where there isn't one, in a passphrase encrypted file.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when there is no secret stored under a name
+var ErrNotFound = errors.New("secret not found")
+
+// Backends
+const (
+	// BackendKeyring keeps secrets in the OS keyring: the Secret Service on linux, the keychain on macOS
+	BackendKeyring = "keyring"
+	// BackendFile keeps secrets in a file encrypted with a passphrase
+	BackendFile = "file"
+)
+
+// refPrefix marks a config value as the name of a secret rather than the secret itself
+const refPrefix = "secret:"
+
+// Store keeps secrets by name
+type Store interface {
+	// Get returns the secret stored under name, or an error wrapping ErrNotFound
+	Get(name string) (string, error)
+	Set(name, value string) error
+	// Delete removes the secret stored under name, it isn't an error if there isn't one
+	Delete(name string) error
+	// Backend is where the secrets are kept, BackendKeyring or BackendFile
+	Backend() string
+}
+
+// Open returns the store for backend, one of BackendKeyring or BackendFile. An
+// empty backend uses the keyring if there is one and the encrypted file if not,
+// decided the first time a secret is needed.
+func Open(backend string) (Store, error) {
+	switch backend {
+	case BackendKeyring:
+		return Keyring{}, nil
+	case BackendFile:
+		path, err := FilePath()
+		if err != nil {
+			return nil, err
+		}
+		return NewFile(path), nil
+	case "":
+		return &auto{}, nil
+	}
+	return nil, fmt.Errorf("unknown secrets backend %q, expected %s or %s", backend, BackendKeyring, BackendFile)
+}
+
+// Ref returns the config value that refers to the secret stored under name
+func Ref(name string) string {
+	return refPrefix + name
+}
+
+// IsRef reports whether a config value refers to a secret, and if so the secret's name
+func IsRef(value string) (string, bool) {
+	if !strings.HasPrefix(value, refPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, refPrefix), true
+}
+
+// Resolve returns the secret a config value refers to, or the value itself if it isn't a reference
+func Resolve(store Store, value string) (string, error) {
+	name, ok := IsRef(value)
+	if !ok {
+		return value, nil
+	}
+	return store.Get(name)
+}
+
+func notFound(name string) error {
+	return fmt.Errorf("%w: %s, add it with zilla secrets set %s", ErrNotFound, name, name)
+}
+
+// auto picks a backend the first time it's used, so that nothing is probed
+// (or prompted for) unless the config actually refers to a secret
+type auto struct {
+	once  sync.Once
+	store Store
+	err   error
+}
+
+func (a *auto) pick() (Store, error) {
+	a.once.Do(func() {
+		if KeyringAvailable() {
+			a.store = Keyring{}
+			return
+		}
+		path, err := FilePath()
+		if err != nil {
+			a.err = err
+			return
+		}
+		a.store = NewFile(path)
+	})
+	return a.store, a.err
+}
+
+func (a *auto) Get(name string) (string, error) {
+	store, err := a.pick()
+	if err != nil {
+		return "", err
+	}
+	return store.Get(name)
+}
+
+func (a *auto) Set(name, value string) error {
+	store, err := a.pick()
+	if err != nil {
+		return err
+	}
+	return store.Set(name, value)
+}
+
+func (a *auto) Delete(name string) error {
+	store, err := a.pick()
+	if err != nil {
+		return err
+	}
+	return store.Delete(name)
+}
+
+func (a *auto) Backend() string {
+	store, err := a.pick()
+	if err != nil {
+		return ""
+	}
+	return store.Backend()
+}
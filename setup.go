@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/jira"
+	"github.com/trevor-atlas/zilla/util"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// verifyTimeout is how long setup waits for jira to answer before giving up on the credentials
+	verifyTimeout = 30 * time.Second
+	// setupWidth is where the form's text wraps
+	setupWidth = 80
+)
+
+// the fields of the setup form, in the order they're asked for
+const (
+	siteField = iota
+	usernameField
+	tokenField
+)
+
+// setupModel is the first-run wizard: it asks where jira is and how to sign
+// in, and checks the answers against jira before anything is saved
+type setupModel struct {
+	app     *util.Zilla
+	inputs  []textinput.Model
+	help    []string
+	focus   int
+	spinner spinner.Model
+	// cancel stops the sign in being checked, it's nil unless one is
+	cancel context.CancelFunc
+	err    error
+	// conf and user are set once jira has accepted the credentials
+	conf util.ConfigData
+	user *jira.IssueUser
+}
+
+type SetupVerified struct {
+	Err  error
+	Conf util.ConfigData
+	User *jira.IssueUser
+}
+
+func newSetupModel(app *util.Zilla) setupModel {
+	conf := app.FileConfig.Jira
+	if profile, ok := app.FileConfig.Profiles[app.Config.Profile]; ok {
+		conf = *profile
+	}
+	site := newSetupInput("acme", conf.Orgname)
+	if conf.CustomDomain != "" {
+		site.SetValue(conf.CustomDomain)
+	}
+	username := newSetupInput("you@example.com", conf.Username)
+	token := newSetupInput("", "")
+	token.EchoMode = textinput.EchoPassword
+	token.EchoCharacter = '•'
+	site.Focus()
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	return setupModel{
+		app:    app,
+		inputs: []textinput.Model{site, username, token},
+		help: []string{
+			"your org name (acme for acme.atlassian.net) or the address of your jira server",
+			"the email you sign in with, leave it blank to use a personal access token",
+			"an api token from id.atlassian.com, or a jira server personal access token",
+		},
+		spinner: s,
+	}
+}
+
+func newSetupInput(placeholder, value string) textinput.Model {
+	t := textinput.New()
+	t.Placeholder = placeholder
+	t.SetValue(value)
+	return t
+}
+
+// siteConf fills in the orgname or customDomain for what was typed into the site field:
+// acme, acme.atlassian.net and https://acme.atlassian.net are all the cloud site acme
+func siteConf(site string, conf *util.Jiraconf) error {
+	site = strings.TrimSuffix(strings.TrimSpace(site), "/")
+	if site == "" {
+		return errors.New("enter your org name or the address of your jira")
+	}
+	conf.Orgname = ""
+	conf.CustomDomain = ""
+	if !strings.Contains(site, ".") && !strings.Contains(site, "://") {
+		conf.Orgname = site
+		return nil
+	}
+	if !strings.Contains(site, "://") {
+		site = "https://" + site
+	}
+	parsed, err := url.Parse(site)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%q isn't an org name or the address of a jira site", site)
+	}
+	if org := strings.TrimSuffix(parsed.Host, ".atlassian.net"); org != parsed.Host && parsed.Path == "" {
+		conf.Orgname = org
+		return nil
+	}
+	conf.CustomDomain = site
+	return nil
+}
+
+// setupConf is the config described by the form, on top of whatever is in the config file already
+func (m setupModel) setupConf() (util.ConfigData, error) {
+	conf := *m.app.FileConfig
+	// the profiles are copied, storing the secrets changes them and the loaded config keeps its values if that fails
+	profiles := make(map[string]*util.Jiraconf, len(conf.Profiles))
+	for n, p := range conf.Profiles {
+		profile := *p
+		profiles[n] = &profile
+	}
+	conf.Profiles = profiles
+	// with --profile, setup fills in that profile rather than the jira section
+	jiraConf := &conf.Jira
+	if name := m.app.Config.Profile; name != "" {
+		if _, ok := profiles[name]; !ok {
+			profiles[name] = &util.Jiraconf{}
+		}
+		jiraConf = profiles[name]
+	}
+	if err := siteConf(m.inputs[siteField].Value(), jiraConf); err != nil {
+		return conf, err
+	}
+	username := strings.TrimSpace(m.inputs[usernameField].Value())
+	token := strings.TrimSpace(m.inputs[tokenField].Value())
+	if token == "" {
+		return conf, errors.New("enter an api token or personal access token")
+	}
+	jiraConf.Username = username
+	if username != "" {
+		jiraConf.Auth.Type = util.AuthBasic
+		jiraConf.Apikey = token
+		jiraConf.AccessToken = ""
+	} else {
+		jiraConf.Auth.Type = util.AuthPAT
+		jiraConf.AccessToken = token
+		jiraConf.Apikey = ""
+	}
+	return conf, nil
+}
+
+// verify signs in to jira with conf, to find out whether the credentials work before they're saved
+func (m setupModel) verify(ctx context.Context, conf util.ConfigData) tea.Cmd {
+	return func() tea.Msg {
+		trial := *m.app
+		effective := conf
+		if profile, ok := conf.Profiles[m.app.Config.Profile]; ok {
+			effective.Profile = m.app.Config.Profile
+			effective.Jira = *profile
+		}
+		trial.Config = &effective
+		user, err := jira.NewService(&trial).GetMyself(ctx)
+		return SetupVerified{Err: err, Conf: conf, User: user}
+	}
+}
+
+func (m setupModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m setupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			m.stopVerifying()
+			return m, tea.Quit
+		case "esc":
+			if m.cancel != nil {
+				m.stopVerifying()
+				return m, nil
+			}
+			return m, tea.Quit
+		}
+		if m.cancel != nil {
+			return m, nil
+		}
+		switch msg.String() {
+		case "tab", "down":
+			return m.focusField(m.focus + 1)
+		case "shift+tab", "up":
+			return m.focusField(m.focus - 1)
+		case "enter":
+			if m.focus < len(m.inputs)-1 {
+				return m.focusField(m.focus + 1)
+			}
+			conf, err := m.setupConf()
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.err = nil
+			ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+			m.cancel = cancel
+			return m, tea.Batch(m.verify(ctx, conf), m.spinner.Tick)
+		}
+		var cmd tea.Cmd
+		m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+		return m, cmd
+
+	case SetupVerified:
+		if m.cancel == nil {
+			// checking was stopped with esc, the answer is for credentials that may have been changed since
+			return m, nil
+		}
+		m.stopVerifying()
+		if msg.Err != nil {
+			m.err = msg.Err
+			return m, nil
+		}
+		m.conf = msg.Conf
+		m.user = msg.User
+		return m, tea.Quit
+
+	case spinner.TickMsg:
+		if m.cancel == nil {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+func (m setupModel) focusField(i int) (setupModel, tea.Cmd) {
+	if i < 0 || i >= len(m.inputs) {
+		return m, nil
+	}
+	m.inputs[m.focus].Blur()
+	m.focus = i
+	return m, m.inputs[m.focus].Focus()
+}
+
+func (m *setupModel) stopVerifying() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+func (m setupModel) View() string {
+	var b strings.Builder
+	b.WriteString(overlayTitleStyle.Render("Welcome to zilla"))
+	b.WriteString("\n")
+	b.WriteString("Let's connect to jira. The token is kept in your keyring, or in an encrypted file if there isn't one.\n\n")
+
+	labels := []string{"Site", "Username", "Token"}
+	for i, input := range m.inputs {
+		b.WriteString(labels[i])
+		b.WriteString("\n")
+		b.WriteString(input.View())
+		b.WriteString("\n")
+		if i == m.focus {
+			b.WriteString(overlayHintStyle.Copy().MarginTop(0).Render(m.help[i]))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	switch {
+	case m.cancel != nil:
+		b.WriteString(fmt.Sprintf("%s signing in to jira... esc to stop", m.spinner.View()))
+	case errors.Is(m.err, jira.ErrUnauthorized):
+		b.WriteString("Could not sign in: jira didn't accept the username and token")
+	case m.err != nil:
+		b.WriteString(fmt.Sprintf("Could not sign in: %s", describeError(m.err)))
+	}
+
+	b.WriteString(overlayHintStyle.Render("tab: next field • enter: sign in • esc: quit"))
+	return overlayStyle.Copy().Width(setupWidth).Render(b.String())
+}
+
+// runSetup walks through setting zilla up and saves the config, moving the
+// token into the secret store. It returns false if setup was abandoned.
+func runSetup(app *util.Zilla) (bool, error) {
+	final, err := tea.NewProgram(newSetupModel(app)).StartReturningModel()
+	if err != nil {
+		return false, err
+	}
+	setup := final.(setupModel)
+	if setup.user == nil {
+		return false, nil
+	}
+
+	conf := setup.conf
+	store, err := app.Secrets()
+	if err != nil {
+		return false, err
+	}
+	// the secret store can ask for a passphrase, which it couldn't do while the form had the terminal
+	if _, err := conf.MigrateSecrets(store); err != nil {
+		return false, fmt.Errorf("error storing the token: %w", err)
+	}
+	if err := app.SaveConfig(&conf); err != nil {
+		return false, err
+	}
+	configPath, _ := util.ConfigPath()
+	fmt.Printf("signed in as %s, the config is saved to %s\n", setup.user.DisplayName, configPath)
+	return true, nil
+}
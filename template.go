@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/trevor-atlas/zilla/jira"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// issueTemplate is a reusable description of an issue, read from a TOML or YAML file.
+// Fields holds any other fields by id or human readable name, eg: "Story Points" = 3
+type issueTemplate struct {
+	Project     string                 `toml:"project" yaml:"project"`
+	Type        string                 `toml:"type" yaml:"type"`
+	Summary     string                 `toml:"summary" yaml:"summary"`
+	Description string                 `toml:"description" yaml:"description"`
+	Labels      []string               `toml:"labels" yaml:"labels"`
+	Fields      map[string]interface{} `toml:"fields" yaml:"fields"`
+}
+
+func loadIssueTemplate(path string) (*issueTemplate, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tmpl issueTemplate
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &tmpl)
+	default:
+		err = toml.Unmarshal(contents, &tmpl)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %s", path, err)
+	}
+	return &tmpl, nil
+}
+
+// createFields turns a template into the fields for CreateIssue, resolving
+// field names and checking the result against the project's create metadata
+func (t issueTemplate) createFields(ctx context.Context, service jira.ClientService) (map[string]interface{}, error) {
+	if t.Project == "" || t.Type == "" || t.Summary == "" {
+		return nil, fmt.Errorf("a template needs at least a project, type and summary")
+	}
+	project, err := service.GetCreateMeta(ctx, t.Project)
+	if err != nil {
+		return nil, err
+	}
+	issueType, ok := project.IssueType(t.Type)
+	if !ok {
+		return nil, fmt.Errorf("%s has no issue type called %q", t.Project, t.Type)
+	}
+
+	fields := map[string]interface{}{
+		"project":   map[string]string{"key": project.Key},
+		"issuetype": map[string]string{"id": issueType.ID},
+		"summary":   t.Summary,
+	}
+	if t.Description != "" {
+		fields["description"] = t.Description
+	}
+	if len(t.Labels) > 0 {
+		fields["labels"] = t.Labels
+	}
+
+	var customFields map[string]string
+	for name, value := range t.Fields {
+		id, ok := issueType.FieldID(name)
+		if !ok {
+			if customFields == nil {
+				mapped, err := service.GetMappedCustomFields(ctx)
+				if err != nil {
+					return nil, err
+				}
+				customFields = *mapped
+			}
+			if id, ok = customFields[name]; !ok {
+				return nil, fmt.Errorf("unknown field %q", name)
+			}
+		}
+		meta, known := issueType.Fields[id]
+		if s, isString := value.(string); isString && known {
+			converted, err := meta.ValueFromString(s)
+			if err != nil {
+				return nil, err
+			}
+			value = converted
+		}
+		fields[id] = value
+	}
+
+	var missing []string
+	for id, meta := range issueType.Fields {
+		if _, ok := fields[id]; !ok && meta.Required && !meta.HasDefaultValue {
+			missing = append(missing, meta.Name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required fields for a %s in %s: %s", issueType.Name, project.Key, strings.Join(missing, ", "))
+	}
+	return fields, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/trevor-atlas/zilla/jira"
+	"strings"
+)
+
+// transitionPicker is the overlay for moving an issue through its workflow.
+// Picking a transition whose screen has required fields (like a resolution)
+// prompts for each of them before the transition is made.
+type transitionPicker struct {
+	key         string
+	loading     bool
+	transitions []jira.Transition
+	cursor      int
+	chosen      *jira.Transition
+	fields      []fieldInput
+	field       int
+}
+
+type GotTransitions struct {
+	Err         error
+	Key         string
+	Transitions []jira.Transition
+}
+
+type TransitionDone struct {
+	Err  error
+	Key  string
+	Name string
+}
+
+func (m Model) fetchTransitions(key string) tea.Cmd {
+	return func() tea.Msg {
+		transitions, err := m.jiraClient.GetTransitions(m.background, key)
+		return GotTransitions{Err: err, Key: key, Transitions: transitions}
+	}
+}
+
+func (m Model) doTransition(key string, transition jira.Transition, fields map[string]interface{}) tea.Cmd {
+	return func() tea.Msg {
+		err := m.jiraClient.DoTransition(m.background, key, transition.ID, fields)
+		return TransitionDone{Err: err, Key: key, Name: transition.To.Name}
+	}
+}
+
+func (m Model) openTransitionPicker() (Model, tea.Cmd) {
+	issue, ok := m.selectedIssue()
+	if !ok {
+		return m, nil
+	}
+	m.transitions = &transitionPicker{key: issue.Key, loading: true}
+	return m, m.fetchTransitions(issue.Key)
+}
+
+func (m Model) updateTransitionPicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.transitions
+	if msg.String() == "esc" {
+		m.transitions = nil
+		return m, nil
+	}
+	if p.loading {
+		return m, nil
+	}
+
+	if p.chosen == nil {
+		switch msg.String() {
+		case "up", "k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case "down", "j":
+			if p.cursor < len(p.transitions)-1 {
+				p.cursor++
+			}
+		case "enter":
+			if len(p.transitions) == 0 {
+				return m, nil
+			}
+			chosen := p.transitions[p.cursor]
+			p.chosen = &chosen
+			for _, id := range chosen.RequiredFields() {
+				p.fields = append(p.fields, newFieldInput(id, chosen.Fields[id]))
+			}
+			if len(p.fields) == 0 {
+				return m, m.submitTransition()
+			}
+		}
+		return m, nil
+	}
+
+	if msg.String() == "enter" {
+		if ok, cmd := m.submitField(p.fields[p.field]); !ok {
+			return m, cmd
+		}
+		if p.field == len(p.fields)-1 {
+			return m, m.submitTransition()
+		}
+		p.field++
+		return m, nil
+	}
+	var cmd tea.Cmd
+	p.fields[p.field], cmd = p.fields[p.field].Update(msg)
+	return m, cmd
+}
+
+func (m Model) submitTransition() tea.Cmd {
+	p := m.transitions
+	p.loading = true
+	var fields map[string]interface{}
+	if len(p.fields) > 0 {
+		fields = make(map[string]interface{})
+		for _, f := range p.fields {
+			// every field was checked as it was submitted
+			fields[f.id], _ = f.Value()
+		}
+	}
+	return m.doTransition(p.key, *p.chosen, fields)
+}
+
+func (p transitionPicker) View() string {
+	var b strings.Builder
+	b.WriteString(overlayTitleStyle.Render(fmt.Sprintf("Transition %s", p.key)))
+	b.WriteString("\n")
+
+	switch {
+	case p.loading && p.chosen == nil:
+		b.WriteString("loading transitions...")
+	case p.loading:
+		b.WriteString(fmt.Sprintf("moving to %s...", p.chosen.To.Name))
+	case p.chosen == nil && len(p.transitions) == 0:
+		b.WriteString("there are no transitions available from this status")
+	case p.chosen == nil:
+		for i, t := range p.transitions {
+			line := t.Name
+			if t.To.Name != "" && t.To.Name != t.Name {
+				line = fmt.Sprintf("%s → %s", t.Name, t.To.Name)
+			}
+			b.WriteString(cursorLine(line, i == p.cursor))
+			b.WriteString("\n")
+		}
+	default:
+		b.WriteString(p.fields[p.field].View())
+	}
+
+	b.WriteString(overlayHintStyle.Render("enter: select • esc: cancel"))
+	return overlayStyle.Render(b.String())
+}
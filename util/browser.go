@@ -0,0 +1,20 @@
+package util
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser opens url in the default browser, as best it can
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -2,90 +2,437 @@ package util
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"github.com/BurntSushi/toml"
-	"github.com/trevor-atlas/zilla/constants"
+	"github.com/trevor-atlas/zilla/dirs"
 	"github.com/trevor-atlas/zilla/logger"
+	"github.com/trevor-atlas/zilla/secrets"
 	"log"
+	"net/http"
 	"os"
 	"path"
+	"sort"
+	"strings"
 )
 
+// Jiraconf describes the jira site and how to sign in to it. Apikey, AccessToken
+// and Auth.ClientSecret can name a secret from the secret store, as
+// secret:<name>, instead of holding the credential itself.
 type Jiraconf struct {
 	Username     string `toml:"username,omitempty"`
 	Apikey       string `toml:"apikey,omitempty"`
 	Orgname      string `toml:"orgname,omitempty"`
 	CustomDomain string `toml:"customDomain,omitempty"`
-	AccessToken  string `toml:"accessToken,omitempty"`
+	// AccessToken is the personal access token used with the pat auth type
+	AccessToken string   `toml:"accessToken,omitempty"`
+	Auth        AuthConf `toml:"auth,omitempty"`
+}
+
+// Auth types
+const (
+	// AuthBasic signs in with username and apikey, an Atlassian API token on Jira Cloud
+	AuthBasic = "basic"
+	// AuthPAT sends accessToken as a bearer token, a personal access token on Jira Server and Data Center
+	AuthPAT = "pat"
+	// AuthOAuth2 signs in through the browser with an OAuth 2.0 (3LO) app on Jira Cloud, see zilla auth login
+	AuthOAuth2 = "oauth2"
+)
+
+// AuthConf picks how zilla signs in to jira
+type AuthConf struct {
+	// Type is one of basic, pat or oauth2. When it's left out, basic is used
+	// if there is an apikey and pat if there is an accessToken.
+	Type string `toml:"type,omitempty"`
+	// ClientID and ClientSecret identify the OAuth 2.0 app, from developer.atlassian.com
+	ClientID     string `toml:"clientId,omitempty"`
+	ClientSecret string `toml:"clientSecret,omitempty"`
+	// CallbackPort is the port of the app's callback url, http://127.0.0.1:<port>/callback
+	CallbackPort int `toml:"callbackPort,omitempty,omitzero"`
+}
+
+// AuthType returns the configured auth type, working it out from the credentials present if it isn't set
+func (j Jiraconf) AuthType() string {
+	switch {
+	case j.Auth.Type != "":
+		return j.Auth.Type
+	case j.Apikey == "" && j.AccessToken != "":
+		return AuthPAT
+	default:
+		return AuthBasic
+	}
+}
+
+// SiteURL is the address of the jira site, https://<orgname>.atlassian.net unless a custom domain is set
+func (j Jiraconf) SiteURL() string {
+	if j.CustomDomain != "" {
+		return j.CustomDomain
+	}
+	return fmt.Sprintf("https://%s.atlassian.net", j.Orgname)
+}
+
+// HTTPConf tunes how requests to jira are retried
+type HTTPConf struct {
+	// MaxRetries is how many times a rate limited or unavailable request is retried, -1 turns retries off
+	MaxRetries int `toml:"maxRetries,omitempty,omitzero"`
+	// RetryWrites retries POST requests as well, which can repeat a write the server did receive
+	RetryWrites bool `toml:"retryWrites,omitempty"`
+}
+
+// SecretsConf picks where the secrets named in the config are kept
+type SecretsConf struct {
+	// Backend is keyring or file. When it's left out the OS keyring is used if
+	// there is one, and a passphrase encrypted file if not.
+	Backend string `toml:"backend,omitempty"`
+}
+
+// LogConf tunes the log, $XDG_STATE_HOME/zilla/log.txt
+type LogConf struct {
+	// Level is the least a record has to matter to be written: debug, info, warn or error, info unless set
+	Level string `toml:"level,omitempty"`
+	// Format is logfmt or json, logfmt unless set
+	Format string `toml:"format,omitempty"`
+	// MaxSize is how many megabytes the log grows to before it's rotated, 5 unless set
+	MaxSize int `toml:"maxSize,omitempty,omitzero"`
+	// MaxFiles is how many rotated logs are kept, 3 unless set
+	MaxFiles int `toml:"maxFiles,omitempty,omitzero"`
+	// TraceHTTP logs every request to jira with its status, latency and headers, credentials
+	// redacted. The traces are info records, so a higher level is lowered to info.
+	TraceHTTP bool `toml:"traceHttp,omitempty"`
+}
+
+// Options are the logger's settings for the log section, with defaults for anything not set
+func (c LogConf) Options() (logger.Options, error) {
+	opts := logger.DefaultOptions
+	if c.Level != "" {
+		level, err := logger.ParseLevel(c.Level)
+		if err != nil {
+			return opts, err
+		}
+		opts.Level = level
+	}
+	switch c.Format {
+	case "":
+	case logger.FormatLogfmt, logger.FormatJSON:
+		opts.Format = c.Format
+	default:
+		return opts, fmt.Errorf("unknown log format %q, expected %s or %s", c.Format, logger.FormatLogfmt, logger.FormatJSON)
+	}
+	// traces are written at the info level, asking for them is asking for that much
+	if c.TraceHTTP && opts.Level > logger.LevelInfo {
+		opts.Level = logger.LevelInfo
+	}
+	if c.MaxSize > 0 {
+		opts.MaxSize = int64(c.MaxSize) << 20
+	}
+	if c.MaxFiles > 0 {
+		opts.MaxFiles = c.MaxFiles
+	}
+	return opts, nil
 }
 
 type ConfigData struct {
-	Jira  Jiraconf `toml:"jira,omitempty"`
-	IsDev bool     `toml:"isDev,omitempty"`
+	// Profile picks one of Profiles to use in place of Jira, unless --profile or ZILLA_PROFILE picks another
+	Profile string      `toml:"profile,omitempty"`
+	Jira    Jiraconf    `toml:"jira,omitempty"`
+	HTTP    HTTPConf    `toml:"http,omitempty"`
+	Secrets SecretsConf `toml:"secrets,omitempty"`
+	Log     LogConf     `toml:"log,omitempty"`
+	IsDev   bool        `toml:"isDev,omitempty"`
+	// Profiles are jira sites to switch between, [profiles.work] and [profiles.oss] say
+	Profiles map[string]*Jiraconf `toml:"profiles,omitempty"`
+}
+
+// ProfileNames lists the profiles in the config, in order
+func (c *ConfigData) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SecretName is what one of a profile's credentials is called in the secret
+// store: jira-apikey, or jira-work-apikey for the work profile
+func SecretName(profile, credential string) string {
+	if profile == "" {
+		return "jira-" + credential
+	}
+	return fmt.Sprintf("jira-%s-%s", profile, credential)
+}
+
+// secretField is a setting that holds a credential, and the name it's kept under in the secret store
+type secretField struct {
+	setting string
+	name    string
+	value   *string
+}
+
+func (c *ConfigData) secretFields() []secretField {
+	fields := jiraSecretFields("jira", "", &c.Jira)
+	for _, name := range c.ProfileNames() {
+		fields = append(fields, jiraSecretFields("profiles."+name, name, c.Profiles[name])...)
+	}
+	return fields
+}
+
+func jiraSecretFields(section, profile string, j *Jiraconf) []secretField {
+	return []secretField{
+		{section + ".apikey", SecretName(profile, "apikey"), &j.Apikey},
+		{section + ".accessToken", SecretName(profile, "access-token"), &j.AccessToken},
+		{section + ".auth.clientSecret", SecretName(profile, "client-secret"), &j.Auth.ClientSecret},
+	}
+}
+
+// PlaintextSecrets lists the settings that hold a credential in the config itself rather than naming a secret
+func (c *ConfigData) PlaintextSecrets() []string {
+	var settings []string
+	for _, field := range c.secretFields() {
+		if _, isRef := secrets.IsRef(*field.value); *field.value != "" && !isRef {
+			settings = append(settings, field.setting)
+		}
+	}
+	return settings
+}
+
+// MigrateSecrets moves the credentials held in the config into store, leaving
+// the names of the secrets in their place. It returns the settings that were
+// moved, the config still has to be saved for them to stay moved.
+func (c *ConfigData) MigrateSecrets(store secrets.Store) ([]string, error) {
+	var moved []string
+	for _, field := range c.secretFields() {
+		if _, isRef := secrets.IsRef(*field.value); *field.value == "" || isRef {
+			continue
+		}
+		if err := store.Set(field.name, *field.value); err != nil {
+			return moved, fmt.Errorf("error storing %s: %w", field.setting, err)
+		}
+		*field.value = secrets.Ref(field.name)
+		moved = append(moved, field.setting)
+	}
+	return moved, nil
+}
+
+// RetryPolicy returns the retry policy described by the http section of the config
+func (c HTTPConf) RetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	switch {
+	case c.MaxRetries < 0:
+		return NoRetries
+	case c.MaxRetries > 0:
+		policy.MaxRetries = c.MaxRetries
+	}
+	if c.RetryWrites {
+		methods := map[string]bool{http.MethodPost: true}
+		for method := range policy.Methods {
+			methods[method] = true
+		}
+		policy.Methods = methods
+	}
+	return policy
 }
 
 type Zilla struct {
+	// Config is the effective config: the file, with the environment and flags on top
 	Config *ConfigData
-	Info   *log.Logger
-	Err    *log.Logger
+	// FileConfig is the config as it is in the file, without overrides, it's what SaveConfig should be given
+	FileConfig *ConfigData
+	// Sources says where each setting in Config came from, by key
+	Sources map[string]Source
+	// Problems are what's wrong with the config, from reading the file, validating it, or the environment and flags
+	Problems []ConfigProblem
+	// Log is the leveled log, configured by the log section. Info and Err write
+	// to it at the info and error levels, for code that logs with Printf.
+	Log  *logger.Logger
+	Info *log.Logger
+	Err  *log.Logger
+
+	// overrides are the settings given as flags, by key
+	overrides map[string]string
+	// secrets is opened for secretsBackend, and reopened if a reload changes the backend
+	secrets        secrets.Store
+	secretsBackend string
+	secretsErr     error
 }
 
-func New() *Zilla {
+// New loads the config, with overrides (settings given as flags, by key) on top of it
+func New(overrides map[string]string) *Zilla {
 	app := new(Zilla)
-	LogInfo, LogErr := logger.GetLoggers()
-	app.Info = LogInfo
-	app.Err = LogErr
-	if config, err := app.GetConfig(); err != nil {
-		app.Config = new(ConfigData)
-	} else {
-		app.Config = config
-	}
+	app.Log = logger.New()
+	app.Info = app.Log.Printer(logger.LevelInfo)
+	app.Err = app.Log.Printer(logger.LevelError)
+	app.overrides = overrides
+	app.load()
 
 	return app
 }
 
-func (a *Zilla) getConfigFileIfExists() (*ConfigData, error) {
-	home, err := os.UserHomeDir()
+// load reads the config file and layers the environment and flags over it
+func (a *Zilla) load() {
+	configPath, _ := ConfigPath()
+	file, inFile, lines, problems := a.getConfigFileIfExists()
+	if file == nil {
+		file = new(ConfigData)
+	}
+	config, sources, errs := layer(file, inFile, getenv(), a.overrides)
+	for _, err := range errs {
+		problems = append(problems, ConfigProblem{Message: err.Error()})
+	}
+	a.FileConfig = file
+	a.Config = config
+	a.Sources = sources
+	if _, fatal := Fatal(problems); !fatal && (ConfigExists() || a.Overridden()) {
+		problems = append(problems, a.validate(configPath, lines)...)
+	}
+	opts, err := a.Config.Log.Options()
+	if err != nil {
+		// validate reports it, with where it was set
+		opts = logger.DefaultOptions
+	}
+	a.Log.Configure(opts)
+	if inFile != nil {
+		a.Log.Info("loaded config", "path", configPath)
+	}
+	for _, problem := range problems {
+		a.Log.Warn("config problem", "problem", problem)
+	}
+	a.Problems = problems
+	// keep the store open across reloads, a file store would ask for its passphrase again
+	if a.secrets == nil || a.secretsBackend != a.Config.Secrets.Backend {
+		a.secrets, a.secretsErr = secrets.Open(a.Config.Secrets.Backend)
+		a.secretsBackend = a.Config.Secrets.Backend
+	}
+}
+
+// UseProfile switches to the named profile, as if it had been picked with --profile
+func (a *Zilla) UseProfile(name string) error {
+	if _, ok := a.FileConfig.Profiles[name]; !ok {
+		return fmt.Errorf("there is no profile %q in the config", name)
+	}
+	overrides := map[string]string{}
+	for key, value := range a.overrides {
+		overrides[key] = value
+	}
+	overrides["profile"] = name
+	a.overrides = overrides
+	a.load()
+	return nil
+}
+
+// CheckProfile returns an error if the profile picked doesn't exist
+func (a *Zilla) CheckProfile() error {
+	if name := a.Config.Profile; name != "" {
+		if _, ok := a.FileConfig.Profiles[name]; !ok {
+			return fmt.Errorf("there is no profile %q in the config, there are: %s", name, strings.Join(a.FileConfig.ProfileNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// Overridden reports whether any setting comes from the environment or flags
+func (a *Zilla) Overridden() bool {
+	for _, source := range a.Sources {
+		if source.Kind == SourceEnv || source.Kind == SourceFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// getConfigFileIfExists reads the config file, along with which keys are set in
+// it and the line each is on. A file that doesn't exist is an empty config, a
+// file that can't be read is reported as a fatal problem.
+func (a *Zilla) getConfigFileIfExists() (*ConfigData, map[string]bool, map[string]int, []ConfigProblem) {
+	configPath, err := ConfigPath()
 	if err != nil {
 		a.Err.Println("error attempting to locate home directory")
-		return nil, errors.New("couldn't locate home directory")
+		return nil, nil, nil, []ConfigProblem{{Message: err.Error(), Fatal: true}}
+	}
+	contents, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		if dirs.ConfigFileGiven() {
+			// most likely a typo in the path, zilla setup creates the file if it's wanted
+			return nil, nil, nil, []ConfigProblem{{File: configPath, Message: "the config file doesn't exist, zilla setup creates it"}}
+		}
+		return nil, nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, []ConfigProblem{{File: configPath, Message: err.Error(), Fatal: true}}
 	}
 	var conf ConfigData
-	configPath := path.Join(home, constants.CONFIG_DIR, constants.CONFIG_FILENAME)
-	_, err = toml.DecodeFile(configPath, &conf)
+	meta, err := toml.Decode(string(contents), &conf)
+	if err != nil {
+		return nil, nil, nil, []ConfigProblem{parseProblem(configPath, err)}
+	}
+	inFile := map[string]bool{}
+	for _, key := range meta.Keys() {
+		inFile[key.String()] = true
+	}
+	lines := keyLines(string(contents))
+	return &conf, inFile, lines, unknownKeys(configPath, meta, &conf, lines)
+}
+
+// ConfigPath is where the config file is kept, the file given with --config or
+// ZILLA_CONFIG, or $XDG_CONFIG_HOME/zilla/zilla.toml
+func ConfigPath() (string, error) {
+	return dirs.ConfigFile()
+}
+
+// ConfigExists reports whether there is a config file to load
+func ConfigExists() bool {
+	configPath, err := ConfigPath()
 	if err != nil {
-		a.Err.Printf("error parsing config file: %#v", err)
-		return nil, fmt.Errorf("error parsing config at \"%v\" ", configPath)
+		return false
 	}
-	a.Info.Printf("successfully loaded config at \"%s\"", configPath)
-	return &conf, nil
+	_, err = os.Stat(configPath)
+	return err == nil
 }
 
-func (a *Zilla) createConfig(config *ConfigData) error {
-	home, err := os.UserHomeDir()
+// SaveConfig writes config to the config file, readable only by the user as it
+// can hold credentials, and reloads it. config should be based on FileConfig,
+// so that overrides from the environment and flags don't end up in the file.
+func (a *Zilla) SaveConfig(config *ConfigData) error {
+	configPath, err := ConfigPath()
 	if err != nil {
 		a.Err.Println("couldn't locate home directory")
-		return errors.New("couldn't locate home directory while attempting to create config file")
+		return fmt.Errorf("%s while attempting to save the config file", err)
 	}
 	var buffer bytes.Buffer
 	if err := toml.NewEncoder(&buffer).Encode(config); err != nil {
-		a.Err.Println("error encoding config file while attempting to create it")
+		a.Err.Println("error encoding config file while attempting to save it")
+		return err
+	}
+	if err := dirs.Ensure(path.Dir(configPath)); err != nil {
+		a.Err.Println("error creating config directory")
+		return err
+	}
+	// WriteFile keeps the mode of a file that's already there, writing a new one and moving it into place doesn't
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, buffer.Bytes(), 0600); err != nil {
+		a.Err.Println("error saving config file")
 		return err
 	}
-	if err := os.WriteFile(path.Join(home, constants.CONFIG_DIR, constants.CONFIG_FILENAME), buffer.Bytes(), 0500); err != nil {
-		a.Err.Println("error creating config file")
+	if err := os.Rename(tmp, configPath); err != nil {
+		a.Err.Println("error saving config file")
 		return err
 	}
-	a.Info.Println("successfully created config file")
+	a.Info.Println("successfully saved config file")
+	a.load()
 	return nil
 }
 
+// Secrets returns the secret store picked by the secrets section of the config
+func (a *Zilla) Secrets() (secrets.Store, error) {
+	return a.secrets, a.secretsErr
+}
+
 func (a *Zilla) GetConfig() (*ConfigData, error) {
-	result, err := a.getConfigFileIfExists()
-	if err != nil {
-		return nil, err
+	result, _, _, problems := a.getConfigFileIfExists()
+	if problem, fatal := Fatal(problems); fatal {
+		return nil, problem
+	}
+	if result == nil {
+		return new(ConfigData), nil
 	}
-
 	return result, nil
 }
@@ -5,11 +5,12 @@ import (
 	"strings"
 )
 
+// getenv collects the ZILLA_ variables from the environment, by name
 func getenv() map[string]string {
 	m := make(map[string]string)
 
 	for _, e := range os.Environ() {
-		if strings.Contains(e, "ZILLA") {
+		if strings.HasPrefix(e, "ZILLA_") {
 			pair := strings.SplitN(e, "=", 2)
 			m[pair[0]] = pair[1]
 		}
@@ -1,28 +1,130 @@
 package util
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"syscall"
 	"time"
 )
 
+// RequestBuilder describes a single request. Start a new one with NewHTTP for
+// every request; a builder isn't safe to share, but any number of separate
+// builders can be sent at once.
 type RequestBuilder interface {
+	// WithContext sets the context the request is sent with, cancelling it aborts the request
+	WithContext(ctx context.Context) RequestBuilder
+	// WithRetry sets which failures are retried, DefaultRetryPolicy unless set
+	WithRetry(policy RetryPolicy) RequestBuilder
 	Body(body io.Reader) RequestBuilder
+	// JSON encodes v as the request body and sets the Content-Type to match
+	JSON(v interface{}) RequestBuilder
+	// Decode parses a successful response's json body into v when the request is sent
+	Decode(v interface{}) RequestBuilder
 	WithHeader(key, value string) RequestBuilder
 	Url(url string) RequestBuilder
 	GET() ([]byte, error)
 	POST() ([]byte, error)
+	PUT() ([]byte, error)
+	PATCH() ([]byte, error)
+	DELETE() ([]byte, error)
 	WithBasicAuth(username, password string) RequestBuilder
+	// WithAuth attaches credentials from auth to each attempt at sending the request
+	WithAuth(auth Authenticator) RequestBuilder
+	// WithTrace has trace called with every attempt at sending the request, once it's answered or has failed
+	WithTrace(trace func(Trace)) RequestBuilder
+	// StatusCode and Header describe the response, once the request has been sent
+	StatusCode() int
+	Header() http.Header
+}
+
+// Authenticator adds credentials to a request before it is sent. It is called
+// for every attempt, so credentials that expire can be renewed between retries.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// NetworkError is returned when a request couldn't reach the server at all,
+// as opposed to the server answering with an error
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return e.Err.Error() }
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// IsNetworkError reports whether err, or any error it wraps, is a NetworkError
+func IsNetworkError(err error) bool {
+	var netErr *NetworkError
+	return errors.As(err, &netErr)
+}
+
+// IsUnsent reports whether err is a NetworkError from before the request left
+// the machine: the host couldn't be looked up or connected to. Any other network
+// error, a timeout say, may have come after the server got the request.
+func IsUnsent(err error) bool {
+	if !IsNetworkError(err) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// StatusError is returned when the server answers with a status outside of 2xx.
+// Body holds the response so the caller can decode whatever error the API sent.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded %s", e.Status)
+}
+
+// defaultClient is shared by every request so connections are reused, http.Client is safe for concurrent use
+var defaultClient = &http.Client{
+	Timeout: time.Second * 10,
 }
 
 type HTTP struct {
-	client  *http.Client
-	request *http.Request
-	body    io.Reader
-	url     string
-	headers map[string]string
+	client   *http.Client
+	ctx      context.Context
+	retry    RetryPolicy
+	response *http.Response
+	body     io.Reader
+	url      string
+	headers  map[string]string
+	into     interface{}
+	auth     Authenticator
+	trace    func(Trace)
+	// err is a problem building the request, returned when it's sent
+	err error
+}
+
+func (h *HTTP) WithContext(ctx context.Context) RequestBuilder {
+	h.ctx = ctx
+	return h
+}
+
+func (h *HTTP) WithRetry(policy RetryPolicy) RequestBuilder {
+	h.retry = policy
+	return h
 }
 
 func (h *HTTP) Url(url string) RequestBuilder {
@@ -35,78 +137,189 @@ func (h *HTTP) Body(body io.Reader) RequestBuilder {
 	return h
 }
 
+func (h *HTTP) JSON(v interface{}) RequestBuilder {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		h.err = fmt.Errorf("error encoding request body: %w", err)
+		return h
+	}
+	h.body = bytes.NewReader(payload)
+	return h.WithHeader("Content-Type", "application/json")
+}
+
+func (h *HTTP) Decode(v interface{}) RequestBuilder {
+	h.into = v
+	return h
+}
+
 func (h *HTTP) WithHeader(key, value string) RequestBuilder {
 	h.headers[key] = value
 	return h
 }
 
 func (h *HTTP) POST() ([]byte, error) {
-	h.request, _ = http.NewRequest(http.MethodPost, h.url, h.body)
+	return h.send(http.MethodPost, h.body)
+}
+
+func (h *HTTP) PUT() ([]byte, error) {
+	return h.send(http.MethodPut, h.body)
+}
+
+func (h *HTTP) PATCH() ([]byte, error) {
+	return h.send(http.MethodPatch, h.body)
+}
+
+func (h *HTTP) DELETE() ([]byte, error) {
+	return h.send(http.MethodDelete, nil)
+}
+
+func (h *HTTP) GET() ([]byte, error) {
+	return h.send(http.MethodGet, nil)
+}
 
-	if len(h.headers) != 0 {
-		for k, v := range h.headers {
-			h.request.Header.Add(k, v)
-			delete(h.headers, k)
+func (h *HTTP) send(method string, body io.Reader) ([]byte, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	// the body is read up front so it can be sent again if the request is retried
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = ioutil.ReadAll(body); err != nil {
+			return nil, err
 		}
 	}
 
-	response, resErr := h.client.Do(h.request)
-	if resErr != nil {
-		return nil, resErr
-	}
+	for attempt := 1; ; attempt++ {
+		resp, contents, err := h.attempt(method, payload, attempt)
+		if err != nil {
+			return nil, err
+		}
+		h.response = resp
 
-	contents, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
+		wait, retry := h.retry.wait(method, resp, attempt)
+		if !retry {
+			return h.result(resp, contents)
+		}
+		r := Retry{Method: method, URL: h.url, StatusCode: resp.StatusCode, Attempt: attempt + 1, Wait: wait}
+		if h.retry.OnRetry != nil {
+			h.retry.OnRetry(r)
+		}
+		notifyRetry(h.ctx, r)
+		if err := sleep(h.ctx, wait); err != nil {
+			return nil, err
+		}
 	}
-
-	return contents, nil
 }
 
-func (h *HTTP) GET() ([]byte, error) {
-	h.request, _ = http.NewRequest(http.MethodGet, h.url, nil)
-
-	if len(h.headers) != 0 {
-		for k, v := range h.headers {
-			h.request.Header.Add(k, v)
+// attempt sends the request once, returning the response with its body read
+func (h *HTTP) attempt(method string, payload []byte, attempt int) (*http.Response, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(h.ctx, method, h.url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range h.headers {
+		request.Header.Add(k, v)
+	}
+	if h.auth != nil {
+		if err := h.auth.Authenticate(h.ctx, request); err != nil {
+			return nil, nil, err
 		}
 	}
-	resp, reqErr := h.client.Do(h.request)
 
+	start := time.Now()
+	resp, reqErr := h.client.Do(request)
+	if h.trace != nil {
+		t := Trace{Method: method, URL: h.url, Attempt: attempt, Latency: time.Since(start), RequestHeader: RedactHeader(request.Header), Err: reqErr}
+		if resp != nil {
+			t.StatusCode = resp.StatusCode
+			t.ResponseHeader = RedactHeader(resp.Header)
+		}
+		h.trace(t)
+	}
 	if reqErr != nil {
-		return nil, reqErr
+		// a cancelled request was stopped on purpose, it doesn't mean the server is unreachable
+		if ctxErr := h.ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+		return nil, nil, &NetworkError{Err: reqErr}
 	}
 
 	defer resp.Body.Close()
-
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return resp, contents, nil
+}
 
+// result turns a response into what GET, POST etc return
+func (h *HTTP) result(resp *http.Response, contents []byte) ([]byte, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return contents, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+			Body:       contents,
+		}
+	}
+	// successful writes often have no content at all
+	if h.into != nil && len(bytes.TrimSpace(contents)) != 0 {
+		if err := json.Unmarshal(contents, h.into); err != nil {
+			return contents, fmt.Errorf("error parsing response json: %w", err)
+		}
+	}
 	return contents, nil
 }
 
+func (h *HTTP) StatusCode() int {
+	if h.response == nil {
+		return 0
+	}
+	return h.response.StatusCode
+}
+
+func (h *HTTP) Header() http.Header {
+	if h.response == nil {
+		return nil
+	}
+	return h.response.Header
+}
+
 func (h *HTTP) WithBasicAuth(username, password string) RequestBuilder {
 	key := encodeBasicAuth(username, password)
 	h.WithHeader("Authorization", "Basic "+key)
 	return h
 }
 
+func (h *HTTP) WithAuth(auth Authenticator) RequestBuilder {
+	h.auth = auth
+	return h
+}
+
+func (h *HTTP) WithTrace(trace func(Trace)) RequestBuilder {
+	h.trace = trace
+	return h
+}
+
+// WithHandler sets how redirects are followed for this request only
 func (h *HTTP) WithHandler(handler func(req *http.Request, via []*http.Request) error) RequestBuilder {
-	h.client.CheckRedirect = handler
+	client := *h.client
+	client.CheckRedirect = handler
+	h.client = &client
 	return h
 }
 
 func NewHTTP() RequestBuilder {
 	h := new(HTTP)
-	h.client = &http.Client{
-		Transport: nil,
-		Jar:       nil,
-		Timeout:   time.Second * 10,
-	}
+	h.client = defaultClient
+	h.ctx = context.Background()
+	h.retry = DefaultRetryPolicy
 	h.headers = make(map[string]string)
-	h.request, _ = http.NewRequest("", "", nil)
 	return h
 }
 
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides which failed requests are tried again and how long to wait between attempts
+type RetryPolicy struct {
+	// MaxRetries is how many times a request is retried after the first attempt, 0 never retries
+	MaxRetries int
+	// BaseDelay is the wait before the first retry, it doubles with each retry after that
+	BaseDelay time.Duration
+	// MaxDelay caps the wait. A server asking for a longer wait with Retry-After isn't retried.
+	MaxDelay time.Duration
+	// Methods are the request methods that can be retried
+	Methods map[string]bool
+	// Statuses are the response statuses that are worth retrying
+	Statuses map[int]bool
+	// OnRetry is called before waiting to retry a request
+	OnRetry func(Retry)
+}
+
+// DefaultRetryPolicy retries rate limited and temporarily unavailable requests. Only
+// idempotent methods are retried, since a POST that timed out may still have happened.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   time.Minute,
+	Methods: map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+	},
+	Statuses: map[int]bool{
+		http.StatusTooManyRequests:    true,
+		http.StatusBadGateway:         true,
+		http.StatusServiceUnavailable: true,
+		http.StatusGatewayTimeout:     true,
+	},
+}
+
+// NoRetries sends every request once
+var NoRetries = RetryPolicy{}
+
+// Retry describes a request that is about to be tried again
+type Retry struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Attempt is the number of the attempt about to be made, the first retry is attempt 2
+	Attempt int
+	Wait    time.Duration
+}
+
+type retryObserverKey struct{}
+
+// WithRetryObserver returns a context that reports the retries of requests sent with it to fn,
+// so whoever started a request can tell the user why it is taking a while
+func WithRetryObserver(ctx context.Context, fn func(Retry)) context.Context {
+	return context.WithValue(ctx, retryObserverKey{}, fn)
+}
+
+func notifyRetry(ctx context.Context, retry Retry) {
+	if fn, ok := ctx.Value(retryObserverKey{}).(func(Retry)); ok {
+		fn(retry)
+	}
+}
+
+// wait returns how long to wait before retrying a response, and whether to retry it at all
+func (p RetryPolicy) wait(method string, resp *http.Response, attempt int) (time.Duration, bool) {
+	if attempt > p.MaxRetries || !p.Methods[method] || !p.Statuses[resp.StatusCode] {
+		return 0, false
+	}
+	if after, ok := retryAfter(resp.Header); ok {
+		return after, after <= p.MaxDelay
+	}
+	// full jitter, so a crowd of clients that were limited together don't all come back together
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1)), true
+}
+
+// retryAfter reads the Retry-After header, which is either a number of seconds or a date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep waits for d, or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,209 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Where a setting's value came from. The config file is overridden by the
+// environment, which is overridden by flags. When a profile is in use its
+// settings replace the jira section of the file.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	// SourceProfile is a jira setting from the profile in use, Name is the profile
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// ConfigKey is a setting, named by its path in zilla.toml: jira.username, http.maxRetries
+type ConfigKey struct {
+	Name string
+	// Env is the variable that overrides the setting: ZILLA_JIRA_USERNAME, ZILLA_HTTP_MAX_RETRIES
+	Env string
+	// Secret is set for credentials, their values are never printed
+	Secret bool
+	// index finds the setting's field in ConfigData
+	index []int
+}
+
+// Flag is the command line flag that overrides the setting: --jira.username
+func (k ConfigKey) Flag() string {
+	return "--" + k.Name
+}
+
+// Source describes where a setting's value came from, for zilla config show --sources
+type Source struct {
+	Kind string
+	// Name is the profile, variable or flag the value was read from, if it wasn't the file
+	Name string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + " " + s.Name
+}
+
+// ConfigKeys lists every setting in ConfigData, in the order they appear in it
+func ConfigKeys() []ConfigKey {
+	secret := map[string]bool{}
+	for _, field := range new(ConfigData).secretFields() {
+		secret[field.setting] = true
+	}
+	keys := configKeys(reflect.TypeOf(ConfigData{}), nil, nil)
+	for i := range keys {
+		keys[i].Secret = secret[keys[i].Name]
+	}
+	return keys
+}
+
+func configKeys(t reflect.Type, path []string, index []int) []ConfigKey {
+	var keys []ConfigKey
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldPath := append(append([]string{}, path...), name)
+		fieldIndex := append(append([]int{}, index...), i)
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(field.Type, fieldPath, fieldIndex)...)
+		case reflect.String, reflect.Int, reflect.Bool:
+			keys = append(keys, ConfigKey{Name: strings.Join(fieldPath, "."), Env: envName(fieldPath), index: fieldIndex})
+		}
+	}
+	return keys
+}
+
+// envName turns a setting's path into the variable that overrides it, jira.customDomain is ZILLA_JIRA_CUSTOM_DOMAIN
+func envName(path []string) string {
+	var b strings.Builder
+	b.WriteString("ZILLA")
+	for _, part := range path {
+		b.WriteString("_")
+		for i, r := range part {
+			if i > 0 && unicode.IsUpper(r) {
+				b.WriteString("_")
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+func findKey(name string) (ConfigKey, error) {
+	for _, key := range ConfigKeys() {
+		if key.Name == name {
+			return key, nil
+		}
+	}
+	return ConfigKey{}, fmt.Errorf("unknown config key %q", name)
+}
+
+// Get returns a setting's value as it would be written in an environment variable
+func (c *ConfigData) Get(name string) (string, error) {
+	key, err := findKey(name)
+	if err != nil {
+		return "", err
+	}
+	v := reflect.ValueOf(c).Elem().FieldByIndex(key.index)
+	switch v.Kind() {
+	case reflect.Int:
+		return strconv.Itoa(int(v.Int())), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	}
+	return v.String(), nil
+}
+
+// Set changes a setting, parsing value to suit it
+func (c *ConfigData) Set(name, value string) error {
+	key, err := findKey(name)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(c).Elem().FieldByIndex(key.index)
+	switch v.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s needs a number, not %q", name, value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s needs true or false, not %q", name, value)
+		}
+		v.SetBool(b)
+	default:
+		v.SetString(value)
+	}
+	return nil
+}
+
+// layer puts the profile in use, the environment and then overrides on top of
+// the config from the file, returning the effective config and where each setting came from
+func layer(file *ConfigData, inFile map[string]bool, env, overrides map[string]string) (*ConfigData, map[string]Source, []error) {
+	config := *file
+	sources := map[string]Source{}
+	var errs []error
+	apply := func(key ConfigKey, source Source) {
+		if value, ok := env[key.Env]; ok {
+			if err := config.Set(key.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("ignoring %s: %w", key.Env, err))
+			} else {
+				source = Source{Kind: SourceEnv, Name: key.Env}
+			}
+		}
+		if value, ok := overrides[key.Name]; ok {
+			if err := config.Set(key.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("ignoring %s: %w", key.Flag(), err))
+			} else {
+				source = Source{Kind: SourceFlag, Name: key.Flag()}
+			}
+		}
+		sources[key.Name] = source
+	}
+	fileSource := func(name string) Source {
+		if inFile[name] {
+			return Source{Kind: SourceFile}
+		}
+		return Source{Kind: SourceDefault}
+	}
+
+	// the profile decides what the jira settings start from, so it's worked out first
+	keys := ConfigKeys()
+	for _, key := range keys {
+		if key.Name == "profile" {
+			apply(key, fileSource(key.Name))
+		}
+	}
+	profile, ok := file.Profiles[config.Profile]
+	if ok {
+		config.Jira = *profile
+	}
+
+	for _, key := range keys {
+		if key.Name == "profile" {
+			continue
+		}
+		source := fileSource(key.Name)
+		if setting := strings.TrimPrefix(key.Name, "jira."); ok && setting != key.Name {
+			source = Source{Kind: SourceDefault}
+			if inFile["profiles."+config.Profile+"."+setting] {
+				source = Source{Kind: SourceProfile, Name: config.Profile}
+			}
+		}
+		apply(key, source)
+	}
+	return &config, sources, errs
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Trace describes one attempt at sending a request, for --trace-http
+type Trace struct {
+	Method  string
+	URL     string
+	Attempt int
+	// StatusCode is 0 when no response came back, Err says why
+	StatusCode int
+	Latency    time.Duration
+	// RequestHeader and ResponseHeader have credentials redacted
+	RequestHeader  http.Header
+	ResponseHeader http.Header
+	Err            error
+}
+
+// credentialHeaders are the headers RedactHeader hides the values of
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// RedactHeader returns a copy of header with credentials hidden. The scheme of
+// an Authorization header is kept, it's often what's wrong.
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range credentialHeaders {
+		values := redacted.Values(name)
+		for i, value := range values {
+			scheme := ""
+			if name == "Authorization" || name == "Proxy-Authorization" {
+				if space := strings.Index(value, " "); space > 0 {
+					scheme = value[:space+1]
+				}
+			}
+			values[i] = scheme + "[redacted]"
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,304 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/trevor-atlas/zilla/secrets"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ConfigProblem is something wrong with the config, found when it's loaded
+type ConfigProblem struct {
+	// File is the config file the problem is in, empty for a problem with the environment or flags
+	File string
+	// Line is where in the file the problem is, 0 when it isn't in the file or the line isn't known
+	Line int
+	// Key is the setting the problem is with, empty when it's with the file as a whole
+	Key     string
+	Message string
+	// Fatal is set when the file couldn't be read at all, none of its settings are used
+	Fatal bool
+}
+
+func (p ConfigProblem) Error() string {
+	var b strings.Builder
+	if p.File != "" {
+		b.WriteString(path.Base(p.File))
+		if p.Line > 0 {
+			fmt.Fprintf(&b, ":%d", p.Line)
+		}
+		b.WriteString(": ")
+	}
+	if p.Key != "" {
+		b.WriteString(p.Key)
+		b.WriteString(": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// Fatal returns the first problem that stopped the config file being read, if there is one
+func Fatal(problems []ConfigProblem) (ConfigProblem, bool) {
+	for _, problem := range problems {
+		if problem.Fatal {
+			return problem, true
+		}
+	}
+	return ConfigProblem{}, false
+}
+
+// parseProblem describes an error decoding the config file
+func parseProblem(file string, err error) ConfigProblem {
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		message := parseErr.Message
+		if message == "" {
+			// the message is only in the formatted error, after the line and last key
+			message = err.Error()
+			if i := strings.Index(message, ": "); i >= 0 && parseErr.LastKey == "" {
+				message = message[i+2:]
+			} else if i := strings.Index(message, "): "); i >= 0 {
+				message = message[i+3:]
+			}
+		}
+		return ConfigProblem{File: file, Line: parseErr.Position.Line, Message: message, Fatal: true}
+	}
+	return ConfigProblem{File: file, Message: strings.TrimPrefix(err.Error(), "toml: "), Fatal: true}
+}
+
+// keyLines finds the line each key in a toml file is set on, and the line of
+// each table's header, by dotted key. It doesn't understand all of toml, only
+// enough to point at the settings zilla has.
+func keyLines(contents string) map[string]int {
+	lines := map[string]int{}
+	table := ""
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "["):
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			table = unquoteKey(strings.Trim(line[:end], "[ "))
+			lines[table] = i + 1
+		default:
+			eq := strings.Index(line, "=")
+			if eq < 0 {
+				continue
+			}
+			key := unquoteKey(line[:eq])
+			if table != "" {
+				key = table + "." + key
+			}
+			lines[key] = i + 1
+		}
+	}
+	return lines
+}
+
+// unquoteKey turns a dotted toml key as written, profiles."work" say, into the form MetaData uses
+func unquoteKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// knownKeys lists every key that can be in the config file, with the
+// jira settings repeated under profiles.<name> for each profile
+func knownKeys(profiles []string) []string {
+	var known []string
+	for _, key := range ConfigKeys() {
+		known = append(known, key.Name)
+		if setting := strings.TrimPrefix(key.Name, "jira."); setting != key.Name {
+			for _, profile := range profiles {
+				known = append(known, "profiles."+profile+"."+setting)
+			}
+		}
+	}
+	return known
+}
+
+// unknownKeys reports the keys in the file that zilla doesn't use, usually typos
+func unknownKeys(file string, meta toml.MetaData, conf *ConfigData, lines map[string]int) []ConfigProblem {
+	known := knownKeys(conf.ProfileNames())
+	var undecoded []string
+	for _, key := range meta.Undecoded() {
+		undecoded = append(undecoded, key.String())
+	}
+	sort.Strings(undecoded)
+
+	var problems []ConfigProblem
+	reported := ""
+	for _, key := range undecoded {
+		// everything in an unknown table is unknown too, the table is enough to report
+		if reported != "" && strings.HasPrefix(key, reported+".") {
+			continue
+		}
+		reported = key
+		message := "unknown setting"
+		if name, ok := closestKey(key, known); ok {
+			message = fmt.Sprintf("unknown setting, did you mean %s?", name)
+		}
+		problems = append(problems, ConfigProblem{File: file, Line: lines[key], Key: key, Message: message})
+	}
+	return problems
+}
+
+// closestKey finds the known key that key is most likely a typo of, if any is close enough
+func closestKey(key string, known []string) (string, bool) {
+	best, bestDistance := "", 3
+	for _, name := range known {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance is the number of single character edits it takes to turn a into b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		row := make([]int, len(b)+1)
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			row[j] = prev[j-1] + cost
+			if prev[j]+1 < row[j] {
+				row[j] = prev[j] + 1
+			}
+			if row[j-1]+1 < row[j] {
+				row[j] = row[j-1] + 1
+			}
+		}
+		prev = row
+	}
+	return prev[len(b)]
+}
+
+// validate checks the effective config makes sense, along with the profiles
+// that aren't in use. lines is where each key is in the file, from keyLines.
+func (a *Zilla) validate(file string, lines map[string]int) []ConfigProblem {
+	var problems []ConfigProblem
+	report := func(key, message string) {
+		problems = append(problems, a.problemAt(file, key, message, lines))
+	}
+
+	checkJira(a.Config.Jira, func(setting, message string) {
+		report("jira."+setting, message)
+	})
+	for _, name := range a.FileConfig.ProfileNames() {
+		if strings.ContainsAny(name, `/\`) {
+			report("profiles."+name, `profile names can't contain / or \`)
+		}
+		if name == a.Config.Profile {
+			// checked above, as the jira settings in use
+			continue
+		}
+		checkJira(*a.FileConfig.Profiles[name], func(setting, message string) {
+			report("profiles."+name+"."+setting, message)
+		})
+	}
+
+	switch a.Config.Secrets.Backend {
+	case "", secrets.BackendKeyring, secrets.BackendFile:
+	default:
+		report("secrets.backend", fmt.Sprintf("unknown secret store %q, expected %s or %s", a.Config.Secrets.Backend, secrets.BackendKeyring, secrets.BackendFile))
+	}
+	if _, err := (LogConf{Level: a.Config.Log.Level}).Options(); err != nil {
+		report("log.level", err.Error())
+	}
+	if _, err := (LogConf{Format: a.Config.Log.Format}).Options(); err != nil {
+		report("log.format", err.Error())
+	}
+	if a.Config.Log.MaxSize < 0 {
+		report("log.maxSize", "can't be negative, leave it out for the default of 5 megabytes")
+	}
+	if a.Config.Log.MaxFiles < 0 {
+		report("log.maxFiles", "can't be negative, leave it out for the default of 3")
+	}
+	if a.Config.HTTP.MaxRetries < -1 {
+		report("http.maxRetries", "can't be less than -1, which turns retries off")
+	}
+	return problems
+}
+
+// problemAt locates a problem with a key of the effective config: the line of
+// the key in the file, or in the profile it came from, or the variable or flag
+// that set it. A key that is missing points at its table instead.
+func (a *Zilla) problemAt(file, key, message string, lines map[string]int) ConfigProblem {
+	fileKey := key
+	if setting := strings.TrimPrefix(key, "jira."); setting != key {
+		if profile := a.Config.Profile; profile != "" {
+			fileKey = "profiles." + profile + "." + setting
+		}
+	}
+	switch source := a.Sources[key]; source.Kind {
+	case SourceEnv, SourceFlag:
+		return ConfigProblem{Key: key, Message: fmt.Sprintf("%s (set by %s)", message, source.Name)}
+	}
+	for k := fileKey; k != ""; {
+		if line, ok := lines[k]; ok {
+			return ConfigProblem{File: file, Line: line, Key: key, Message: message}
+		}
+		dot := strings.LastIndex(k, ".")
+		if dot < 0 {
+			break
+		}
+		k = k[:dot]
+	}
+	return ConfigProblem{File: file, Key: key, Message: message}
+}
+
+// checkJira reports what's missing or malformed in the settings for a jira site, by setting
+func checkJira(j Jiraconf, report func(setting, message string)) {
+	switch {
+	case j.CustomDomain != "":
+		if u, err := url.Parse(j.CustomDomain); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			report("customDomain", fmt.Sprintf("%q isn't the address of a site, it should look like https://jira.example.com", j.CustomDomain))
+		} else if u.RawQuery != "" || u.Fragment != "" {
+			report("customDomain", fmt.Sprintf("%q should be just the address of the site, without a query or #fragment", j.CustomDomain))
+		}
+	case j.Orgname == "":
+		report("orgname", "set orgname, or customDomain for a jira server, to say which site to use")
+	case strings.ContainsAny(j.Orgname, "./:"):
+		report("orgname", fmt.Sprintf("%q should be just the org name, acme for acme.atlassian.net, put a full address in customDomain", j.Orgname))
+	}
+
+	switch j.AuthType() {
+	case AuthBasic:
+		if j.Username == "" {
+			report("username", "basic auth needs the email you sign in to jira with")
+		}
+		if j.Apikey == "" {
+			report("apikey", "basic auth needs an api token, from id.atlassian.com")
+		}
+	case AuthPAT:
+		if j.AccessToken == "" {
+			report("accessToken", "pat auth needs a personal access token")
+		}
+	case AuthOAuth2:
+		if j.Auth.ClientID == "" {
+			report("auth.clientId", "oauth2 needs the client id of an app from developer.atlassian.com")
+		}
+		if j.Auth.ClientSecret == "" {
+			report("auth.clientSecret", "oauth2 needs the client secret of an app from developer.atlassian.com")
+		}
+	default:
+		report("auth.type", fmt.Sprintf("unknown auth type %q, expected %s, %s or %s", j.Auth.Type, AuthBasic, AuthPAT, AuthOAuth2))
+	}
+}